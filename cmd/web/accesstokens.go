@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// accessTokenExpiryDays are the lifetimes users can choose from when they
+// create a personal access token. 0 means the token never expires.
+var accessTokenExpiryDays = []int{0, 7, 30, 90, 365}
+
+// accessTokensPage holds what the tokens page shows besides the form.
+type accessTokensPage struct {
+	Tokens []models.AccessToken
+	// New is the plaintext of a token which has just been created. It's
+	// only ever shown once.
+	New        string
+	Scopes     []string
+	ExpiryDays []int
+}
+
+// accessTokenExpires returns when a token created now with the given
+// lifetime expires, or the zero time if it never does.
+func accessTokenExpires(days int) time.Time {
+	if days == 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, days)
+}
+
+// tokenError rejects a request made with a personal access token, using the
+// error codes from RFC 6750 so that clients can tell what went wrong.
+func (app *application) tokenError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q`, code))
+	app.clientError(w, status)
+}
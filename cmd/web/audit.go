@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// accountAuditEvents is how many recent events are shown on the account
+// page.
+const accountAuditEvents = 10
+
+// auditEventNames describe each audit event on the account and admin pages.
+var auditEventNames = map[string]string{
+	models.AuditLogin:          "Logged in",
+	models.AuditLoginFailed:    "Failed login",
+	models.AuditLogout:         "Logged out",
+	models.AuditPasswordChange: "Changed password",
+	models.AuditPasswordReset:  "Reset password",
+	models.AuditEmailChange:    "Changed email address",
+	models.AuditTokenCreate:    "Created access token",
+	models.AuditTokenRevoke:    "Revoked access token",
+	models.AuditSessionRevoke:  "Logged out other sessions",
+}
+
+func auditEventName(event string) string {
+	if name, ok := auditEventNames[event]; ok {
+		return name
+	}
+	return event
+}
+
+// audit records an account event in the audit log. A failure is only
+// logged: the audit log must never stop anyone from logging in or out.
+func (app *application) audit(r *http.Request, userID int, event, detail string) {
+	err := app.auditEvents.Record(models.AuditEvent{
+		UserID:    userID,
+		Event:     event,
+		Detail:    truncate(detail, 255),
+		IP:        clientIP(r),
+		UserAgent: truncate(r.UserAgent(), 255),
+	})
+	if err != nil {
+		app.logger.Error("recording audit event", "error", err.Error(), "event", event, "user", userID)
+	}
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// auditLoginFailed records a failed password login. If the email address
+// belongs to an account, the event is added to that account's log so its
+// owner can see it.
+func (app *application) auditLoginFailed(r *http.Request, email string) {
+	user, err := app.users.GetByEmail(email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.logger.Error("recording audit event", "error", err.Error(), "event", models.AuditLoginFailed)
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditLoginFailed, email)
+}
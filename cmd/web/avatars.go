@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// maxAvatarSize is the largest profile picture which can be uploaded.
+const maxAvatarSize = 2 << 20
+
+// avatarCacheControl lets browsers keep avatars forever. The URL changes
+// whenever the user changes their picture.
+const avatarCacheControl = "public, max-age=31536000, immutable"
+
+// avatarURL returns the URL of the user's current avatar in the given size.
+// Users who haven't uploaded a picture get an identicon.
+func avatarURL(user models.User, size int) string {
+	return fmt.Sprintf("/avatars/%d/%d/%d", user.ID, user.AvatarVersion, size)
+}
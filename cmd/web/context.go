@@ -3,3 +3,5 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const authenticatedUserContextKey = contextKey("authenticatedUser")
+const accessTokenContextKey = contextKey("accessToken")
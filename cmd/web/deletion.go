@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Choices for what happens to a user's snippets when their account is
+// deleted.
+const (
+	deletionKeepSnippets   = "anonymize"
+	deletionDeleteSnippets = "delete"
+)
+
+// accountDeletionInterval is how often accounts whose grace period has passed
+// are deleted.
+const accountDeletionInterval = time.Hour
+
+// deleteScheduledAccounts deletes the accounts whose grace period has passed,
+// and any sessions they still have.
+func (app *application) deleteScheduledAccounts(ctx context.Context) error {
+	ids, err := app.users.DeleteScheduled()
+
+	for _, id := range ids {
+		app.logger.Info("account deleted", "userID", id)
+
+		sessionErr := app.destroyUserSessions(ctx, id)
+		if sessionErr != nil {
+			return sessionErr
+		}
+	}
+
+	return err
+}
+
+// runAccountDeletion calls deleteScheduledAccounts every interval until ctx
+// is cancelled.
+func (app *application) runAccountDeletion(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := app.deleteScheduledAccounts(ctx)
+		if err != nil {
+			app.logger.Error("account deletion failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+const (
+	renewSnippetPurpose = "renew-snippet"
+	// snippetRenewDays is how much longer a snippet lives after it's renewed
+	// from a digest.
+	snippetRenewDays = 7
+)
+
+// digestExpiringWithin is how far ahead digests look for expiring snippets,
+// digestFollowingLimit is how many new snippets by followed users a digest
+// lists at most, and digestInterval is how often the job checks who is due
+// a digest.
+const (
+	digestExpiringWithin = 48 * time.Hour
+	digestFollowingLimit = 10
+	digestInterval       = time.Hour
+)
+
+// digestSnippet is a snippet listed in a digest email.
+type digestSnippet struct {
+	Title    string
+	URL      string
+	RenewURL string
+	Created  string
+	Expires  string
+}
+
+// digestSince returns the time from which a digest lists new snippets by
+// followed users: the last digest, or one period ago for a user's first.
+func digestSince(d models.DigestRecipient) time.Time {
+	if !d.LastSent.IsZero() {
+		return d.LastSent
+	}
+
+	if d.Frequency == models.DigestWeekly {
+		return time.Now().Add(-7 * 24 * time.Hour)
+	}
+	return time.Now().Add(-24 * time.Hour)
+}
+
+// digestFollowing returns the snippets by the people the user follows which
+// were created since their last digest, newest first.
+func (app *application) digestFollowing(d models.DigestRecipient) ([]models.Snippet, error) {
+	feed, err := app.snippets.Feed(d.UserID, 0, digestFollowingLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	since := digestSince(d)
+
+	// The feed is newest first, so everything after the first older snippet
+	// was in an earlier digest.
+	for i, s := range feed {
+		if !s.Created.After(since) {
+			return feed[:i], nil
+		}
+	}
+
+	return feed, nil
+}
+
+// snippetRenewURL returns a one-click link which renews the snippet. The
+// link is signed, so it works without logging in, and carries the current
+// expiry time, so it only works once.
+func (app *application) snippetRenewURL(s models.Snippet) string {
+	payload := fmt.Sprintf("%d:%d:%d", s.ID, s.UserID, s.Expires.Unix())
+	token := app.signer.Sign(renewSnippetPurpose, payload, s.Expires)
+
+	return fmt.Sprintf("%s/snippet/renew/%s/", app.baseURL, url.PathEscape(token))
+}
+
+// parseRenewToken checks a token from a renew link and returns the snippet
+// ID, the user ID and the expiry time it was issued for.
+func (app *application) parseRenewToken(token string) (int, int, time.Time, error) {
+	payload, err := app.signer.Verify(renewSnippetPurpose, token, time.Now())
+	if err != nil {
+		return 0, 0, time.Time{}, err
+	}
+
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 {
+		return 0, 0, time.Time{}, fmt.Errorf("malformed renew payload")
+	}
+
+	var values [3]int64
+	for i, part := range parts {
+		values[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, 0, time.Time{}, err
+		}
+	}
+
+	return int(values[0]), int(values[1]), time.Unix(values[2], 0), nil
+}
+
+// renewLinkFailed sends the user to the home page with a message saying the
+// renew link can't be used.
+func (app *application) renewLinkFailed(w http.ResponseWriter, r *http.Request) {
+	app.sessionManager.Put(r.Context(), "flash", "That renew link is invalid or has already been used, or the snippet has expired.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// sendDigests emails a digest to every user who is due one. A failure to
+// send one user's digest is logged and doesn't stop the others; that user
+// gets another try next time.
+func (app *application) sendDigests(ctx context.Context) error {
+	recipients, err := app.digests.Due()
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	snippets, err := app.snippets.Expiring(digestExpiringWithin)
+	if err != nil {
+		return err
+	}
+
+	expiring := make(map[int][]models.Snippet)
+	for _, s := range snippets {
+		expiring[s.UserID] = append(expiring[s.UserID], s)
+	}
+
+	for _, d := range recipients {
+		err = app.sendDigest(d, expiring[d.UserID])
+		if err != nil {
+			app.logger.Error("sending digest", "error", err.Error(), "user", d.UserID)
+			continue
+		}
+
+		err = app.digests.Sent(d.UserID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendDigest emails one user their digest: their snippets which expire
+// soon, and what the people they follow have posted since the last one.
+// Nothing is sent if there's nothing to tell them.
+func (app *application) sendDigest(d models.DigestRecipient, expiring []models.Snippet) error {
+	following, err := app.digestFollowing(d)
+	if err != nil {
+		return err
+	}
+
+	if len(expiring) == 0 && len(following) == 0 {
+		return nil
+	}
+
+	loc := models.Preferences{Timezone: d.Timezone}.Location()
+
+	expiringItems := make([]digestSnippet, len(expiring))
+	for i, s := range expiring {
+		expiringItems[i] = digestSnippet{
+			Title:    s.Title,
+			URL:      app.digestSnippetURL(s),
+			RenewURL: app.snippetRenewURL(s),
+			Expires:  humanDate(s.Expires, loc),
+		}
+	}
+
+	followingItems := make([]digestSnippet, len(following))
+	for i, s := range following {
+		followingItems[i] = digestSnippet{
+			Title:   s.Title,
+			URL:     app.digestSnippetURL(s),
+			Created: humanDate(s.Created, loc),
+		}
+	}
+
+	data := map[string]any{
+		"Name":           d.Name,
+		"Frequency":      d.Frequency,
+		"Expiring":       expiringItems,
+		"Following":      followingItems,
+		"RenewDays":      snippetRenewDays,
+		"PreferencesURL": app.baseURL + "/account/preferences/",
+	}
+
+	return app.sendEmail(d.Email, "digest.tmpl", data)
+}
+
+// digestSnippetURL returns the full URL of a snippet's page.
+func (app *application) digestSnippetURL(s models.Snippet) string {
+	return fmt.Sprintf("%s/snippet/view/%d/", app.baseURL, s.ID)
+}
+
+// runDigests calls sendDigests every interval until ctx is cancelled.
+func (app *application) runDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := app.sendDigests(ctx)
+		if err != nil {
+			app.logger.Error("digests failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+const (
+	changeEmailPurpose = "change-email"
+	changeEmailTTL     = 24 * time.Hour
+)
+
+// sendEmailChangeEmails emails a signed confirmation link to the new address
+// and tells the old address about the request. Both addresses are part of the
+// signed payload, so the link stops working once the address has changed,
+// whether through this link or another one.
+func (app *application) sendEmailChangeEmails(user models.User, newEmail string) error {
+	payload := strconv.Itoa(user.ID) + ":" + user.Email + ":" + newEmail
+	token := app.signer.Sign(changeEmailPurpose, payload, time.Now().Add(changeEmailTTL))
+
+	data := map[string]any{
+		"Name":      user.Name,
+		"NewEmail":  newEmail,
+		"URL":       fmt.Sprintf("%s/user/email/confirm/%s/", app.baseURL, url.PathEscape(token)),
+		"ExpiresIn": "24 hours",
+		"ResetURL":  app.baseURL + "/user/password/forgot/",
+	}
+
+	err := app.sendEmail(newEmail, "email-change-confirm.tmpl", data)
+	if err != nil {
+		return err
+	}
+
+	return app.sendEmail(user.Email, "email-change-notice.tmpl", data)
+}
+
+// parseEmailChangeToken checks a token from an email change link and returns
+// the user ID and the old and new email addresses it was issued for.
+func (app *application) parseEmailChangeToken(token string) (int, string, string, error) {
+	payload, err := app.signer.Verify(changeEmailPurpose, token, time.Now())
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	// Email addresses accepted by validator.EmailRX can't contain a colon.
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 {
+		return 0, "", "", fmt.Errorf("malformed email change payload")
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return id, parts[1], parts[2], nil
+}
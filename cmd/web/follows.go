@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// Which list of people a follow list page shows.
+const (
+	followListFollowing = "following"
+	followListFollowers = "followers"
+)
+
+// followListPageSize is how many people a follow list page shows.
+const followListPageSize = 50
+
+// cursorLinks holds the cursors for a list which is paged by ID rather than
+// by page number. Before is the cursor of the current page and Next that of
+// the page after it; both are 0 if there's no such page.
+type cursorLinks struct {
+	Before int
+	Next   int
+}
+
+// followPage holds the follow details shown on profiles and follow lists.
+type followPage struct {
+	Following int
+	Followers int
+	// IsFollowing is set when the current user follows the profile's user,
+	// and Self when it's their own profile.
+	IsFollowing bool
+	Self        bool
+	// List is followListFollowing or followListFollowers on the follow list
+	// pages.
+	List string
+}
+
+// parseBefore reads the ?before= cursor of a keyset paginated list. It
+// returns 0 if there isn't one, and false if it's malformed.
+func parseBefore(r *http.Request) (int, bool) {
+	b := r.URL.Query().Get("before")
+	if b == "" {
+		return 0, true
+	}
+
+	before, err := strconv.Atoi(b)
+	if err != nil || before < 1 {
+		return 0, false
+	}
+
+	return before, true
+}
+
+// profileUser looks up the user whose profile the URL points to. It sends a
+// 404 and returns false if there's no such user or they're suspended.
+func (app *application) profileUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, err := app.users.GetByUsername(r.PathValue("username"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.User{}, false
+	}
+
+	if user.Suspended {
+		http.NotFound(w, r)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// followDetails returns the follow counts for user and whether the current
+// user follows them.
+func (app *application) followDetails(r *http.Request, user models.User) (followPage, error) {
+	var page followPage
+	var err error
+
+	page.Following, page.Followers, err = app.follows.Counts(user.ID)
+	if err != nil {
+		return followPage{}, err
+	}
+
+	if !app.isAuthenticated(r) {
+		return page, nil
+	}
+
+	current := app.authenticatedUser(r).ID
+	if current == user.ID {
+		page.Self = true
+		return page, nil
+	}
+
+	page.IsFollowing, err = app.follows.IsFollowing(current, user.ID)
+	if err != nil {
+		return followPage{}, err
+	}
+
+	return page, nil
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/markponce/snippetbox/internal/avatar"
+	"github.com/markponce/snippetbox/internal/langdetect"
 	"github.com/markponce/snippetbox/internal/models"
+	"github.com/markponce/snippetbox/internal/totp"
 	"github.com/markponce/snippetbox/internal/validator"
+	"github.com/skip2/go-qrcode"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +33,39 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
 }
 
+// homeFollowing shows the "Following" tab of the home page: the newest
+// snippets by the people the user follows.
+func (app *application) homeFollowing(w http.ResponseWriter, r *http.Request) {
+	before, ok := parseBefore(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	perPage := data.Preferences.PageSize
+
+	// As on profiles, ask for one snippet more than fits on the page to find
+	// out whether there's a next page.
+	snippets, err := app.snippets.Feed(app.authenticatedUser(r).ID, before, perPage+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	cursor := cursorLinks{Before: before}
+	if len(snippets) > perPage {
+		snippets = snippets[:perPage]
+		cursor.Next = snippets[perPage-1].ID
+	}
+
+	data.Snippets = snippets
+	data.Following = true
+	data.Cursor = cursor
+
+	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
+}
+
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 
@@ -35,30 +78,232 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
+		} else if errors.Is(err, models.ErrHidden) {
+			app.clientError(w, http.StatusGone)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	app.renderSnippetView(w, r, http.StatusOK, snippet, snippetReportForm{})
+}
+
+// renderSnippetView renders a snippet's page with the given report form, so
+// a report with errors is shown just like the page it was sent from.
+func (app *application) renderSnippetView(w http.ResponseWriter, r *http.Request, status int, snippet models.Snippet, form snippetReportForm) {
+	// Snippets created before accounts existed, or kept after their author
+	// deleted their account, have no author.
+	var author models.User
+	if snippet.UserID != 0 {
+		var err error
+		author, err = app.users.Get(snippet.UserID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Author = author
+	data.User = app.authenticatedUser(r)
+	data.Form = form
+	data.ReportReasons = models.ReportReasons
+
+	app.render(w, r, status, "view.tmpl.html", data)
+}
+
+type snippetReportForm struct {
+	Reason              string `form:"reason"`
+	Note                string `form:"note"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetReportPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else if errors.Is(err, models.ErrHidden) {
+			app.clientError(w, http.StatusGone)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var form snippetReportForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Reason, models.ReportReasons...), "reason", "Please choose a reason")
+	form.CheckField(validator.MaxChars(form.Note, 1000), "note", "This field cannot be more than 1000 characters long")
+
+	if !form.Valid() {
+		app.renderSnippetView(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+
+	_, err = app.moderation.Report(snippet.ID, userID, form.Reason, form.Note)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, the snippet has been reported to the moderators.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d/", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetDeletePost lets the author of a snippet delete it.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else if errors.Is(err, models.ErrHidden) {
+			app.clientError(w, http.StatusGone)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.UserID == 0 || snippet.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.snippets.Delete(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 type snippetCreateForm struct {
 	Title               string `form:"title"`
 	Content             string `form:"content"`
 	Expires             int    `form:"expires"`
+	Language            string `form:"language"`
+	LanguageDetected    bool   `form:"languageDetected"`
+	AcknowledgeSecrets  bool   `form:"acknowledgeSecrets"`
+	SecretsFound        bool   `form:"-"`
 	validator.Validator `form:"-"`
 }
 
+// validate checks the snippet fields. It is shared by snippetCreatePost and the
+// bulk import so both apply exactly the same rules.
+func (form *snippetCreateForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Expires, snippetExpiryDays...), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(form.Language == "" || validator.PermittedValue(form.Language, langdetect.IDs()...), "language", "This field must be one of the listed languages")
+}
+
+// detectLanguage fills in the language when the user didn't pick one and
+// returns the confidence of the guess. A language which was pre-filled from
+// an earlier guess and left unchanged still counts as detected. The
+// confidence is 0 when the user picked the language themselves.
+func (form *snippetCreateForm) detectLanguage() float64 {
+	if form.Language != "" && !form.LanguageDetected {
+		return 0
+	}
+
+	guess := langdetect.Detect(form.Content)
+
+	if form.Language == "" {
+		form.Language = guess.Language
+	} else if form.Language != guess.Language {
+		form.LanguageDetected = false
+		return 0
+	}
+
+	form.LanguageDetected = form.Language != ""
+	return guess.Confidence
+}
+
+// snippetRenew shows the page that renew links in digest emails lead to.
+// The snippet is only renewed once the button on it is pressed, so mail
+// scanners which follow links don't renew anything. The signed link is
+// enough to prove who sent it, so it works without logging in.
+func (app *application) snippetRenew(w http.ResponseWriter, r *http.Request) {
+	id, _, expires, err := app.parseRenewToken(r.PathValue("token"))
+	if err != nil {
+		app.renewLinkFailed(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) || errors.Is(err, models.ErrHidden) {
+			app.renewLinkFailed(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The link has already been used if the expiry has moved on.
+	if snippet.Expires.Unix() != expires.Unix() {
+		app.renewLinkFailed(w, r)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.RenewToken = r.PathValue("token")
+	data.RenewDays = snippetRenewDays
+	app.render(w, r, http.StatusOK, "renew.tmpl.html", data)
+}
+
+func (app *application) snippetRenewPost(w http.ResponseWriter, r *http.Request) {
+	id, userID, expires, err := app.parseRenewToken(r.PathValue("token"))
+	if err == nil {
+		err = app.snippets.Renew(id, userID, expires, snippetRenewDays)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if err != nil {
+		app.renewLinkFailed(w, r)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your snippet has been renewed for another %d days.", snippetRenewDays))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d/", id), http.StatusSeeOther)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// w.Write(([]byte("Display a form for creating a new snippet...")))
 	data := app.newTemplateData(r)
 	data.Form = snippetCreateForm{
-		// Expires: 365,
+		Expires: data.Preferences.DefaultExpires,
 	}
 	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
 }
@@ -72,10 +317,18 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.validate()
+
+	// This also pre-fills the language picker when the form is shown again.
+	confidence := form.detectLanguage()
+
+	// Depending on the server configuration, snippets which look like they
+	// contain secrets are either rejected or need an explicit acknowledgement.
+	findings := app.scanForSecrets(r, form.Content)
+	if len(findings) > 0 && (app.secretsMode == secretsModeReject || !form.AcknowledgeSecrets) {
+		form.SecretsFound = app.secretsMode == secretsModeWarn
+		form.AddNonFieldError(app.secretsMessage(findings))
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -84,7 +337,13 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	id, err := app.snippets.Insert(app.authenticatedUser(r).ID, models.SnippetEntry{
+		Title:              form.Title,
+		Content:            form.Content,
+		Expires:            form.Expires,
+		Language:           form.Language,
+		LanguageConfidence: confidence,
+	})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -95,17 +354,162 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+type snippetImportForm struct {
+	Expires               int  `form:"expires"`
+	AcknowledgeSecrets    bool `form:"acknowledgeSecrets"`
+	CanAcknowledgeSecrets bool `form:"-"`
+	validator.Validator   `form:"-"`
+}
+
+func (app *application) accountImport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = snippetImportForm{
+		CanAcknowledgeSecrets: app.secretsMode == secretsModeWarn,
+	}
+	app.render(w, r, http.StatusOK, "import.tmpl.html", data)
+}
+
+func (app *application) accountImportPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetImportForm
+
+	err := app.decodeMultipartForm(r, &form, maxImportSize)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Expires, snippetExpiryDays...), "expires", "This field must equal 1, 7 or 365")
+	form.CanAcknowledgeSecrets = app.secretsMode == secretsModeWarn
+
+	var entries []importEntry
+
+	file, header, err := r.FormFile("archive")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		form.AddFieldError("archive", "Please choose a file to import")
+	case err != nil:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case header.Size > maxImportSize:
+		file.Close()
+		form.AddFieldError("archive", fmt.Sprintf("The file cannot be larger than %d MB", maxImportSize>>20))
+	default:
+		defer file.Close()
+
+		buf, err := io.ReadAll(file)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		entries, err = parseImportArchive(buf, form.Expires)
+		if err != nil {
+			form.AddFieldError("archive", err.Error())
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "import.tmpl.html", data)
+		return
+	}
+
+	// Validate every entry with the same rules as snippetCreatePost and only
+	// keep the ones which pass.
+	var (
+		report  importReport
+		valid   []models.SnippetEntry
+		created []importResult
+	)
+
+	for _, e := range entries {
+		result := importResult{Name: e.Name, Title: e.Title}
+
+		if e.Problem != "" {
+			result.Reasons = []string{e.Problem}
+			report.Rejected = append(report.Rejected, result)
+			continue
+		}
+
+		entryForm := snippetCreateForm{
+			Title:            e.Title,
+			Content:          e.Content,
+			Expires:          e.Expires,
+			Language:         e.Language,
+			LanguageDetected: e.LanguageConfidence > 0,
+		}
+		entryForm.validate()
+
+		confidence := e.LanguageConfidence
+		if e.Language == "" {
+			confidence = entryForm.detectLanguage()
+		}
+
+		findings := app.scanForSecrets(r, e.Content)
+		if len(findings) > 0 && (app.secretsMode == secretsModeReject || !form.AcknowledgeSecrets) {
+			entryForm.AddNonFieldError(app.secretsMessage(findings))
+		}
+
+		if !entryForm.Valid() {
+			result.Reasons = append(fieldErrorMessages(entryForm.FieldErrors), entryForm.NonFieldErrors...)
+			report.Rejected = append(report.Rejected, result)
+			continue
+		}
+
+		valid = append(valid, models.SnippetEntry{
+			Title:              entryForm.Title,
+			Content:            entryForm.Content,
+			Expires:            entryForm.Expires,
+			Language:           entryForm.Language,
+			LanguageConfidence: confidence,
+		})
+		created = append(created, result)
+	}
+
+	if len(valid) > 0 {
+		ids, err := app.snippets.InsertMany(app.authenticatedUser(r).ID, valid)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		for i := range created {
+			created[i].ID = ids[i]
+		}
+		report.Created = created
+	}
+
+	data := app.newTemplateData(r)
+	data.ImportReport = report
+	app.render(w, r, http.StatusOK, "import-report.tmpl.html", data)
+}
+
 type userSignupForm struct {
 	Name     string `form:"name"`
+	Username string `form:"username"`
 	Email    string `form:"email"`
 	Password string `form:"password"`
+	Invite   string `form:"invite"`
+	// InviteOnly is set when signing up needs an invite code.
+	InviteOnly bool `form:"-"`
 	validator.Validator
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	// fmt.Fprintln(w, "Display a form for signing up a new user...")
 	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
+	// Invite links carry the code in the query string, so it's filled in
+	// for the user.
+	data.Form = userSignupForm{
+		Invite:     r.URL.Query().Get("invite"),
+		InviteOnly: app.signupMode == signupModeInvite,
+	}
 	app.render(w, r, http.StatusOK, "signup.tmpl.html", data)
 }
 
@@ -119,11 +523,24 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	form.Username = normalizeUsername(form.Username)
+	form.Invite = strings.TrimSpace(form.Invite)
+	form.InviteOnly = app.signupMode == signupModeInvite
+
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	checkUsername(&form.Validator, form.Username)
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	if form.InviteOnly {
+		form.CheckField(validator.NotBlank(form.Invite), "invite", "You need an invite code to sign up")
+	}
+
+	err = app.checkNewPassword(&form.Validator, "password", form.Password, form.Name, form.Username, form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
 
@@ -134,21 +551,40 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	var id int
+	if form.InviteOnly {
+		id, err = app.users.InsertWithInvite(form.Name, form.Username, form.Email, form.Password, form.Invite)
+	} else {
+		id, err = app.users.Insert(form.Name, form.Username, form.Email, form.Password)
+	}
 
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		switch {
+		case errors.Is(err, models.ErrInvalidInvite):
+			form.AddFieldError("invite", "This invite code is invalid, used up or expired")
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
-		} else {
+		case errors.Is(err, models.ErrDuplicateUsername):
+			form.AddFieldError("username", "Username is already taken")
+		default:
 			app.serverError(w, r, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		return
+	}
+
+	// The account exists at this point, so a failed email shouldn't fail the
+	// signup. The user can ask for the verification email again later.
+	err = app.sendVerificationEmail(models.User{ID: id, Name: form.Name, Email: form.Email})
+	if err != nil {
+		app.logger.Error("sending verification email", "error", err.Error(), "user", id)
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please check your email to verify your address, then log in.")
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
@@ -185,32 +621,105 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse to check the password at all while the account or the client is
+	// being throttled. The message doesn't say which, or whether the account
+	// exists.
+	if !app.loginAllowed(r, form.Email) {
+		form.AddNonFieldError("Too many failed login attempts. Please wait a while and try again.")
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "login.tmpl.html", data)
+		return
+	}
+
 	// Check whether the credentials are valid. If they're not, add a generic
 	// non-field error message and re-display the login page.
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.loginFailed(r, form.Email)
+			app.auditLoginFailed(r, form.Email)
 			form.AddNonFieldError("Emai or Password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		} else if errors.Is(err, models.ErrSuspended) {
+			form.AddNonFieldError("Your account has been suspended")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusForbidden, "login.tmpl.html", data)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	app.beginLogin(w, r, id)
+}
+
+// beginLogin is called once the user has proven who they are with their
+// password or an external identity provider. Users with two-factor
+// authentication turned on aren't logged in yet: remember who they are and
+// ask for their second factor.
+func (app *application) beginLogin(w http.ResponseWriter, r *http.Request, id int) {
+	_, err := app.twoFactor.Secret(id)
+	if err == nil {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), string(pendingUserIDSessionKey), id)
+		app.sessionManager.Put(r.Context(), string(pendingSinceSessionKey), time.Now().Unix())
+		app.sessionManager.Remove(r.Context(), string(twoFactorAttemptsSessionKey))
+
+		http.Redirect(w, r, "/user/login/2fa/", http.StatusSeeOther)
+		return
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.completeLogin(w, r, id)
+}
+
+// completeLogin logs the user in once they've proven who they are.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, id int) {
 	// Use the RenewToken() method on the current session to change the session
 	// ID. It's good practice to generate a new session ID when the
 	// authentication state or privilege levels changes for the user (e.g. login
 	// and logout operations).
-	err = app.sessionManager.RenewToken(r.Context())
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Failed attempts are only forgotten once every factor has been checked,
+	// so that knowing the password doesn't give unlimited guesses at the
+	// second factor.
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.loginThrottle.Reset(loginThrottleKey(user.Email))
+
+	// Logging in during the grace period cancels a scheduled deletion.
+	cancelled, err := app.users.CancelDeletion(id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	if cancelled {
+		app.sessionManager.Put(r.Context(), "flash", "Welcome back! Your account is no longer scheduled for deletion.")
+	}
+
 	app.sessionManager.Put(r.Context(), string(authenticatedUserIDSessionKey), id)
+	app.recordSessionMetadata(r)
+	app.audit(r, id, models.AuditLogin, "")
 
 	// Redirect if user access a protected route before login
 	rURL := app.sessionManager.PopString(r.Context(), string(postLoginRedirectURLSessionKey))
@@ -223,53 +732,1959 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	// Use the RenewToken() method on the current session to change the session
-	// ID again.
-	err := app.sessionManager.RenewToken(r.Context())
-	if err != nil {
-		app.serverError(w, r, err)
-	}
+func (app *application) userLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	login := newOIDCLogin()
+	app.sessionManager.Put(r.Context(), string(oidcStateSessionKey), login.State)
+	app.sessionManager.Put(r.Context(), string(oidcNonceSessionKey), login.Nonce)
+	app.sessionManager.Put(r.Context(), string(oidcVerifierSessionKey), login.Verifier)
+
+	http.Redirect(w, r, app.oidc.AuthCodeURL(login), http.StatusSeeOther)
+}
+
+// oidcLoginFailed sends the user back to the login page with a message.
+func (app *application) oidcLoginFailed(w http.ResponseWriter, r *http.Request, message string) {
+	app.sessionManager.Put(r.Context(), "flash", message)
+	http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+}
+
+func (app *application) userLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The values are removed from the session so each one is only used
+	// once.
+	login := oidcLogin{
+		State:    app.sessionManager.PopString(r.Context(), string(oidcStateSessionKey)),
+		Nonce:    app.sessionManager.PopString(r.Context(), string(oidcNonceSessionKey)),
+		Verifier: app.sessionManager.PopString(r.Context(), string(oidcVerifierSessionKey)),
+	}
+
+	query := r.URL.Query()
+
+	if login.State == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		app.oidcLoginFailed(w, r, "Your login has expired. Please try again.")
+		return
+	}
+
+	if query.Get("error") != "" {
+		app.logger.Info("oidc login refused", "error", query.Get("error"), "description", query.Get("error_description"))
+		app.oidcLoginFailed(w, r, fmt.Sprintf("Login with %s was cancelled.", app.oidc.Name))
+		return
+	}
+
+	claims, err := app.oidc.Exchange(r.Context(), login, query.Get("code"))
+	if err != nil {
+		app.logger.Warn("oidc login failed", "error", err.Error())
+		app.oidcLoginFailed(w, r, fmt.Sprintf("We couldn't log you in with %s. Please try again.", app.oidc.Name))
+		return
+	}
+
+	id, err := app.identities.Get(app.oidc.issuer, claims.Subject)
+	if errors.Is(err, models.ErrNoRecord) {
+		// First login with this identity: link it to the account with the
+		// same email address. Both sides must have verified the address,
+		// otherwise someone could sign up with another person's address
+		// and wait for them to log in.
+		if claims.Email == "" || !claims.EmailVerified {
+			app.oidcLoginFailed(w, r, fmt.Sprintf("%s hasn't verified your email address, so we can't find your account.", app.oidc.Name))
+			return
+		}
+
+		user, err := app.users.GetByEmail(claims.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.oidcLoginFailed(w, r, fmt.Sprintf("There's no account for %s. Please sign up first.", claims.Email))
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if !user.EmailVerified {
+			app.oidcLoginFailed(w, r, fmt.Sprintf("Please verify your email address by logging in with your password before using %s.", app.oidc.Name))
+			return
+		}
+
+		err = app.identities.Link(user.ID, app.oidc.issuer, claims.Subject)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.logger.Info("linked oidc identity", "user", user.ID, "issuer", app.oidc.issuer)
+		id = user.ID
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		// The identity may still be linked to an account which has since
+		// been deleted.
+		if errors.Is(err, models.ErrNoRecord) {
+			app.oidcLoginFailed(w, r, fmt.Sprintf("There's no account linked to your %s login. Please sign up first.", app.oidc.Name))
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if user.Suspended {
+		app.oidcLoginFailed(w, r, "Your account has been suspended")
+		return
+	}
+
+	app.beginLogin(w, r, id)
+}
+
+type userLoginLinkForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userLoginLink(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userLoginLinkForm{}
+	app.render(w, r, http.StatusOK, "login-link.tmpl.html", data)
+}
+
+func (app *application) userLoginLinkPost(w http.ResponseWriter, r *http.Request) {
+	var form userLoginLinkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login-link.tmpl.html", data)
+		return
+	}
+
+	// Like the forgotten password page, the response is the same whether or
+	// not the address belongs to an account, and failures to send the email
+	// are only logged.
+	user, err := app.users.GetByEmail(form.Email)
+	if err == nil && !user.Suspended {
+		err = app.sendLoginLinkEmail(user, app.loginLinkNonce(r))
+		if err != nil {
+			app.logger.Error("sending login link email", "error", err.Error(), "user", user.ID)
+		}
+	} else if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a login link. Open it in this browser within 15 minutes.")
+	http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+}
+
+func (app *application) userLoginLinkUse(w http.ResponseWriter, r *http.Request) {
+	nonce := app.sessionManager.GetString(r.Context(), string(loginLinkNonceSessionKey))
+	if nonce == "" {
+		app.loginLinkFailed(w, r, "This login link doesn't work in this browser. Please open it in the browser you asked for it from, or ask for a new one.")
+		return
+	}
+
+	id, err := app.loginLinks.Use(r.PathValue("token"), nonce)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.loginLinkFailed(w, r, "This login link is invalid, has expired or was asked for in another browser. Please ask for a new one.")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), string(loginLinkNonceSessionKey))
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.Suspended {
+		app.loginLinkFailed(w, r, "Your account has been suspended")
+		return
+	}
+
+	app.beginLogin(w, r, id)
+}
+
+type userTwoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// pendingUserID returns the user who entered the right password but hasn't
+// entered their second factor yet. It returns 0 if there's no such user or
+// they took too long.
+func (app *application) pendingUserID(r *http.Request) int {
+	since := app.sessionManager.GetInt64(r.Context(), string(pendingSinceSessionKey))
+	if time.Since(time.Unix(since, 0)) > pendingLoginTTL {
+		return 0
+	}
+	return app.sessionManager.GetInt(r.Context(), string(pendingUserIDSessionKey))
+}
+
+// clearPendingLogin forgets the half-finished login.
+func (app *application) clearPendingLogin(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), string(pendingUserIDSessionKey))
+	app.sessionManager.Remove(r.Context(), string(pendingSinceSessionKey))
+	app.sessionManager.Remove(r.Context(), string(twoFactorAttemptsSessionKey))
+}
+
+func (app *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if app.pendingUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userTwoFactorForm{}
+	app.render(w, r, http.StatusOK, "login-2fa.tmpl.html", data)
+}
+
+func (app *application) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	id := app.pendingUserID(r)
+	if id == 0 {
+		app.clearPendingLogin(r)
+		app.sessionManager.Put(r.Context(), "flash", "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+		return
+	}
+
+	var form userTwoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Wrong codes count towards the same limits as wrong passwords.
+	if form.Valid() && !app.loginAllowed(r, user.Email) {
+		form.AddNonFieldError("Too many failed login attempts. Please wait a while and try again.")
+		data := app.newTemplateData(r)
+		data.Form = userTwoFactorForm{Validator: form.Validator}
+		app.render(w, r, http.StatusTooManyRequests, "login-2fa.tmpl.html", data)
+		return
+	}
+
+	if form.Valid() {
+		ok, err := app.checkSecondFactor(id, form.Code)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if ok {
+			app.clearPendingLogin(r)
+			app.completeLogin(w, r, id)
+			return
+		}
+
+		app.loginFailed(r, user.Email)
+		app.audit(r, id, models.AuditLoginFailed, "wrong two-factor code")
+
+		attempts := app.sessionManager.GetInt(r.Context(), string(twoFactorAttemptsSessionKey)) + 1
+		if attempts >= maxTwoFactorAttempts {
+			app.clearPendingLogin(r)
+			app.sessionManager.Put(r.Context(), "flash", "Too many wrong codes. Please log in again.")
+			http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), string(twoFactorAttemptsSessionKey), attempts)
+
+		form.AddFieldError("code", "This code is not valid")
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userTwoFactorForm{Validator: form.Validator}
+	app.render(w, r, http.StatusUnprocessableEntity, "login-2fa.tmpl.html", data)
+}
+
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	currentID := app.sessionManager.GetString(r.Context(), string(sessionIDSessionKey))
+
+	sessions, err := app.userSessions(r.Context(), user.ID, currentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Sessions = sessions
+	app.render(w, r, http.StatusOK, "account-sessions.tmpl.html", data)
+}
+
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// The current session is ended by logging out instead.
+	if id == app.sessionManager.GetString(r.Context(), string(sessionIDSessionKey)) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	found, err := app.destroySession(r.Context(), app.authenticatedUser(r).ID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	app.audit(r, app.authenticatedUser(r).ID, models.AuditSessionRevoke, "one session")
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been logged out.")
+	http.Redirect(w, r, "/account/sessions/", http.StatusSeeOther)
+}
+
+func (app *application) accountSessionsRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	currentID := app.sessionManager.GetString(r.Context(), string(sessionIDSessionKey))
+
+	err := app.destroyOtherSessions(r.Context(), app.authenticatedUser(r).ID, currentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, app.authenticatedUser(r).ID, models.AuditSessionRevoke, "all other sessions")
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out everywhere else.")
+	http.Redirect(w, r, "/account/sessions/", http.StatusSeeOther)
+}
+
+type accessTokenForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	ExpiresDays         int      `form:"expiresDays"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAccessTokens(w, r, http.StatusOK, accessTokenForm{ExpiresDays: 30}, "")
+}
+
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form accessTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Choose at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.Scopes...), "scopes", "Choose from the listed scopes")
+	}
+	form.CheckField(validator.PermittedValue(form.ExpiresDays, accessTokenExpiryDays...), "expiresDays", "Choose from the listed expiry times")
+
+	if !form.Valid() {
+		app.renderAccessTokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	token, err := app.accessTokens.New(user.ID, form.Name, form.Scopes, accessTokenExpires(form.ExpiresDays))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditTokenCreate, form.Name)
+
+	// The token is rendered straight away instead of redirecting, so the
+	// plaintext never has to be stored, not even in the session.
+	app.renderAccessTokens(w, r, http.StatusOK, accessTokenForm{ExpiresDays: 30}, token)
+}
+
+func (app *application) renderAccessTokens(w http.ResponseWriter, r *http.Request, status int, form accessTokenForm, newToken string) {
+	tokens, err := app.accessTokens.List(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AccessTokens = accessTokensPage{
+		Tokens:     tokens,
+		New:        newToken,
+		Scopes:     models.Scopes,
+		ExpiryDays: accessTokenExpiryDays,
+	}
+	app.render(w, r, status, "account-tokens.tmpl.html", data)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	err = app.accessTokens.Revoke(user.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditTokenRevoke, fmt.Sprintf("token %d", id))
+
+	app.sessionManager.Put(r.Context(), "flash", "The token has been revoked.")
+	http.Redirect(w, r, "/account/tokens/", http.StatusSeeOther)
+}
+
+type inviteForm struct {
+	MaxUses             int `form:"maxUses"`
+	ExpiresDays         int `form:"expiresDays"`
+	validator.Validator `form:"-"`
+}
+
+// Users can only use the invite pages if -user-invites is set. Admins use
+// the same pages under /admin/, where they can see and revoke everyone's
+// invites.
+func (app *application) accountInvites(w http.ResponseWriter, r *http.Request) {
+	if !app.userInvites {
+		http.NotFound(w, r)
+		return
+	}
+	app.renderInvites(w, r, http.StatusOK, inviteForm{MaxUses: 1, ExpiresDays: 7}, "", false)
+}
+
+func (app *application) accountInvitesPost(w http.ResponseWriter, r *http.Request) {
+	if !app.userInvites {
+		http.NotFound(w, r)
+		return
+	}
+	app.createInvite(w, r, false)
+}
+
+func (app *application) accountInviteRevokePost(w http.ResponseWriter, r *http.Request) {
+	if !app.userInvites {
+		http.NotFound(w, r)
+		return
+	}
+	app.revokeInvite(w, r, false)
+}
+
+func (app *application) createInvite(w http.ResponseWriter, r *http.Request, admin bool) {
+	var form inviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(form.MaxUses >= 1 && form.MaxUses <= inviteMaxUses, "maxUses", fmt.Sprintf("This field must be between 1 and %d", inviteMaxUses))
+	form.CheckField(validator.PermittedValue(form.ExpiresDays, inviteExpiryDays...), "expiresDays", "Choose from the listed expiry times")
+
+	if !form.Valid() {
+		app.renderInvites(w, r, http.StatusUnprocessableEntity, form, "", admin)
+		return
+	}
+
+	code, err := app.invites.New(app.authenticatedUser(r).ID, form.MaxUses, inviteExpires(form.ExpiresDays))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderInvites(w, r, http.StatusOK, inviteForm{MaxUses: 1, ExpiresDays: 7}, code, admin)
+}
+
+func (app *application) revokeInvite(w http.ResponseWriter, r *http.Request, admin bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Admins can revoke anyone's invites.
+	createdBy := app.authenticatedUser(r).ID
+	if admin {
+		createdBy = 0
+	}
+
+	err = app.invites.Revoke(id, createdBy)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The invite has been revoked.")
+	http.Redirect(w, r, invitesPath(admin), http.StatusSeeOther)
+}
+
+func (app *application) renderInvites(w http.ResponseWriter, r *http.Request, status int, form inviteForm, newCode string, admin bool) {
+	createdBy := app.authenticatedUser(r).ID
+	if admin {
+		createdBy = 0
+	}
+
+	invites, err := app.invites.List(createdBy)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Invites = invitesPage{
+		Invites:    invites,
+		New:        newCode,
+		Path:       invitesPath(admin),
+		SignupURL:  app.baseURL + "/user/signup/?invite=",
+		ExpiryDays: inviteExpiryDays,
+		MaxUses:    inviteMaxUses,
+		Admin:      admin,
+	}
+	app.render(w, r, status, "invites.tmpl.html", data)
+}
+
+func (app *application) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Form = userTwoFactorForm{}
+
+	if user.TwoFactorEnabled {
+		n, err := app.twoFactor.RecoveryCodesLeft(user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.TwoFactor.CodesLeft = n
+	} else {
+		// Keep the same secret if the page is reloaded, in case the QR
+		// code has already been scanned.
+		secret := app.sessionManager.GetString(r.Context(), string(totpEnrollSecretSessionKey))
+		if secret == "" {
+			secret = totp.GenerateSecret()
+			app.sessionManager.Put(r.Context(), string(totpEnrollSecretSessionKey), secret)
+		}
+		data.TwoFactor.Secret = secret
+	}
+
+	app.render(w, r, http.StatusOK, "account-2fa.tmpl.html", data)
+}
+
+// accountTwoFactorQR serves the QR code for the secret being set up as a PNG
+// image, so the secret never leaves the server in a third-party request.
+func (app *application) accountTwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	secret := app.sessionManager.GetString(r.Context(), string(totpEnrollSecretSessionKey))
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	png, err := qrcode.Encode(totp.URL(totpIssuer, user.Email, secret), qrcode.Medium, 256)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+func (app *application) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	secret := app.sessionManager.GetString(r.Context(), string(totpEnrollSecretSessionKey))
+	if user.TwoFactorEnabled || secret == "" {
+		http.Redirect(w, r, "/account/2fa/", http.StatusSeeOther)
+		return
+	}
+
+	var form userTwoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The first code proves the authenticator app was set up correctly.
+	step, ok := totp.Validate(secret, form.Code, time.Now())
+	form.CheckField(ok, "code", "This code is not valid. Check the time on your device and try again")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.User = user
+		data.Form = userTwoFactorForm{Validator: form.Validator}
+		data.TwoFactor.Secret = secret
+		app.render(w, r, http.StatusUnprocessableEntity, "account-2fa.tmpl.html", data)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(user.ID, secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Don't accept the code used for setup again at login.
+	err = app.twoFactor.UseStep(user.ID, step)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), string(totpEnrollSecretSessionKey))
+
+	user.TwoFactorEnabled = true
+
+	// The recovery codes are only ever shown on this page.
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Form = userTwoFactorForm{}
+	data.Flash = "Two-factor authentication is now on."
+	data.TwoFactor.RecoveryCodes = codes
+	data.TwoFactor.CodesLeft = len(codes)
+	app.render(w, r, http.StatusOK, "account-2fa.tmpl.html", data)
+}
+
+func (app *application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if !user.TwoFactorEnabled {
+		http.Redirect(w, r, "/account/2fa/", http.StatusSeeOther)
+		return
+	}
+
+	var form userTwoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Ask for a code so that someone using an unattended session can't turn
+	// two-factor authentication off. Wrong codes count towards the same
+	// limits as at login, so the code can't be guessed either.
+	status := http.StatusUnprocessableEntity
+
+	if !app.loginAllowed(r, user.Email) {
+		form.AddFieldError("code", "Too many failed attempts. Please wait a while and try again.")
+		status = http.StatusTooManyRequests
+	} else {
+		ok, err := app.checkSecondFactor(user.ID, form.Code)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.loginFailed(r, user.Email)
+			app.audit(r, user.ID, models.AuditLoginFailed, "wrong two-factor code when turning it off")
+		}
+
+		form.CheckField(ok, "code", "This code is not valid")
+	}
+
+	if !form.Valid() {
+		n, err := app.twoFactor.RecoveryCodesLeft(user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.User = user
+		data.Form = userTwoFactorForm{Validator: form.Validator}
+		data.TwoFactor.CodesLeft = n
+		app.render(w, r, status, "account-2fa.tmpl.html", data)
+		return
+	}
+
+	err = app.twoFactor.Disable(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is now off.")
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+type userForgotPasswordForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userForgotPasswordForm{}
+	app.render(w, r, http.StatusOK, "password-forgot.tmpl.html", data)
+}
+
+func (app *application) userForgotPasswordPost(w http.ResponseWriter, r *http.Request) {
+	var form userForgotPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password-forgot.tmpl.html", data)
+		return
+	}
+
+	// The response is the same whether or not the address belongs to an
+	// account, so this page can't be used to find out who has signed up.
+	// For the same reason, failures to send the email are only logged.
+	user, err := app.users.GetByEmail(form.Email)
+	if err == nil {
+		err = app.sendPasswordResetEmail(user)
+		if err != nil {
+			app.logger.Error("sending password reset email", "error", err.Error(), "user", user.ID)
+		}
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a link to reset the password.")
+	http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+}
+
+type userResetPasswordForm struct {
+	NewPassword         string `form:"newPassword"`
+	ConfirmPassword     string `form:"confirmPassword"`
+	Token               string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// checkPasswordResetToken redirects back to the forgotten password page and
+// returns false if the token in the URL isn't usable. Otherwise it returns
+// the token and the ID of the user it belongs to.
+func (app *application) checkPasswordResetToken(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	token := r.PathValue("token")
+
+	userID, err := app.passwordResets.Check(token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That password reset link is invalid or has expired. Please ask for a new one.")
+			http.Redirect(w, r, "/user/password/forgot/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return "", 0, false
+	}
+
+	return token, userID, true
+}
+
+func (app *application) userResetPassword(w http.ResponseWriter, r *http.Request) {
+	token, _, ok := app.checkPasswordResetToken(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userResetPasswordForm{Token: token}
+	app.render(w, r, http.StatusOK, "password-reset.tmpl.html", data)
+}
+
+func (app *application) userResetPasswordPost(w http.ResponseWriter, r *http.Request) {
+	token, userID, ok := app.checkPasswordResetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var form userResetPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Token = token
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.ConfirmPassword, form.NewPassword), "confirmPassword", "New password and confirm password is not the same")
+
+	user, err := app.users.Get(userID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.checkNewPassword(&form.Validator, "newPassword", form.NewPassword, user.Name, user.Username, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		// Don't send the passwords back to the browser.
+		data.Form = userResetPasswordForm{Token: token, Validator: form.Validator}
+		app.render(w, r, http.StatusUnprocessableEntity, "password-reset.tmpl.html", data)
+		return
+	}
+
+	userID, err = app.passwordResets.Reset(token, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			// The token was used or expired since it was checked above.
+			app.sessionManager.Put(r.Context(), "flash", "That password reset link is invalid or has expired. Please ask for a new one.")
+			http.Redirect(w, r, "/user/password/forgot/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Whoever knew the old password may still be logged in, so log the user
+	// out everywhere.
+	err = app.destroyUserSessions(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The current session is saved again after the handler returns, so it
+	// has to be logged out separately.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Remove(r.Context(), string(authenticatedUserIDSessionKey))
+
+	app.audit(r, userID, models.AuditPasswordReset, "")
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in with your new password.")
+	http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	app.audit(r, app.authenticatedUser(r).ID, models.AuditLogout, "")
+
+	// Use the RenewToken() method on the current session to change the session
+	// ID again.
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+
+	// Remove the authenticatedUserID from the session data so that the user is
+	// 'logged out'.
+	app.sessionManager.Remove(r.Context(), string(authenticatedUserIDSessionKey))
+
+	// Add a flash message to the session to confirm to the user that they've been
+	// logged out.
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+
+	// Redirect the user to the application home page.
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) moderationView(w http.ResponseWriter, r *http.Request) {
+	reports, err := app.moderation.OpenReports()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	log, err := app.moderation.Log(50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Reports = reports
+	data.ModerationLog = log
+
+	app.render(w, r, http.StatusOK, "moderation.tmpl.html", data)
+}
+
+type moderationActionForm struct {
+	Action string `form:"action"`
+}
+
+func (app *application) moderationResolvePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form moderationActionForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !validator.PermittedValue(form.Action, models.ModerationHide, models.ModerationDelete, models.ModerationDismiss, models.ModerationSuspend) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	moderatorID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+
+	report, err := app.moderation.Resolve(id, moderatorID, form.Action)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That report has already been resolved.")
+		} else if errors.Is(err, models.ErrNoAuthor) {
+			app.sessionManager.Put(r.Context(), "flash", "That snippet has no author to suspend.")
+		} else {
+			app.serverError(w, r, err)
+			return
+		}
+	} else {
+		app.logger.Info("moderation action", "moderator", moderatorID, "report", id, "action", form.Action)
+		app.publish(r.Context(), reportResolvedEvent{Report: report, Action: form.Action})
+		app.sessionManager.Put(r.Context(), "flash", "The report has been resolved.")
+	}
+
+	http.Redirect(w, r, "/moderation/", http.StatusSeeOther)
+}
+
+// adminStats is shown on the admin dashboard.
+type adminStats struct {
+	models.Stats
+	Sessions      int
+	Authenticated int
+	GoVersion     string
+	Goroutines    int
+	HeapAllocMB   uint64
+}
+
+// adminConfirmation describes an admin action waiting to be confirmed.
+type adminConfirmation struct {
+	Message string
+	Action  string
+	Cancel  string
+}
+
+type adminSearchForm struct {
+	Q string `form:"q"`
+}
+
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.stats.Get()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.AdminStats.Stats = stats
+
+	err = app.sessionManager.Iterate(r.Context(), func(ctx context.Context) error {
+		data.AdminStats.Sessions++
+		if app.sessionManager.GetInt(ctx, string(authenticatedUserIDSessionKey)) != 0 {
+			data.AdminStats.Authenticated++
+		}
+		return nil
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	data.AdminStats.GoVersion = runtime.Version()
+	data.AdminStats.Goroutines = runtime.NumGoroutine()
+	data.AdminStats.HeapAllocMB = mem.HeapAlloc >> 20
+
+	app.render(w, r, http.StatusOK, "admin.tmpl.html", data)
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	var form adminSearchForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	users, err := app.users.Search(strings.TrimSpace(form.Q), 50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Lockouts are kept in memory rather than in the users table.
+	locked := make(map[int]bool)
+	for _, u := range users {
+		if !app.loginThrottle.LockedUntil(loginThrottleKey(u.Email)).IsZero() {
+			locked[u.ID] = true
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Users = users
+	data.LockedUsers = locked
+
+	app.render(w, r, http.StatusOK, "admin-users.tmpl.html", data)
+}
+
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	var form adminSearchForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := app.auditEvents.Search(strings.TrimSpace(form.Q), 100)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditEvents = events
+
+	app.render(w, r, http.StatusOK, "admin-audit.tmpl.html", data)
+}
+
+func (app *application) adminInvites(w http.ResponseWriter, r *http.Request) {
+	app.renderInvites(w, r, http.StatusOK, inviteForm{MaxUses: 1, ExpiresDays: 7}, "", true)
+}
+
+func (app *application) adminInvitesPost(w http.ResponseWriter, r *http.Request) {
+	app.createInvite(w, r, true)
+}
+
+func (app *application) adminInviteRevokePost(w http.ResponseWriter, r *http.Request) {
+	app.revokeInvite(w, r, true)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	var form adminSearchForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, err := app.snippets.Search(strings.TrimSpace(form.Q), 50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "admin-snippets.tmpl.html", data)
+}
+
+// adminTargetUser reads the user and action from the URL of an admin user
+// action. It writes an error response and returns false if either is invalid.
+func (app *application) adminTargetUser(w http.ResponseWriter, r *http.Request) (models.User, string, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return models.User{}, "", false
+	}
+
+	action := r.PathValue("action")
+	if !validator.PermittedValue(action, models.AdminSuspend, models.AdminUnsuspend, models.AdminResetSessions, models.AdminUnlock) {
+		http.NotFound(w, r)
+		return models.User{}, "", false
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.User{}, "", false
+	}
+
+	// Stop administrators from locking themselves out by accident.
+	if user.ID == app.authenticatedUser(r).ID && action != models.AdminResetSessions {
+		app.clientError(w, http.StatusBadRequest)
+		return models.User{}, "", false
+	}
+
+	return user, action, true
+}
+
+func (app *application) adminUserAction(w http.ResponseWriter, r *http.Request) {
+	user, action, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var message string
+	switch action {
+	case models.AdminSuspend:
+		message = fmt.Sprintf("Suspend %s (%s)? They will be logged out and won't be able to log in again.", user.Name, user.Email)
+	case models.AdminUnsuspend:
+		message = fmt.Sprintf("Reinstate %s (%s)?", user.Name, user.Email)
+	case models.AdminResetSessions:
+		message = fmt.Sprintf("Log %s (%s) out of every session?", user.Name, user.Email)
+	case models.AdminUnlock:
+		message = fmt.Sprintf("Clear the lockout and failed login attempts of %s (%s)?", user.Name, user.Email)
+	}
+
+	data := app.newTemplateData(r)
+	data.Confirm = adminConfirmation{
+		Message: message,
+		Action:  r.URL.Path,
+		Cancel:  "/admin/users/",
+	}
+
+	app.render(w, r, http.StatusOK, "admin-confirm.tmpl.html", data)
+}
+
+type adminConfirmForm struct {
+	Confirm bool `form:"confirm"`
+}
+
+func (app *application) adminUserActionPost(w http.ResponseWriter, r *http.Request) {
+	user, action, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var form adminConfirmForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !form.Confirm {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case models.AdminSuspend:
+		err = app.users.SetSuspended(user.ID, true)
+		if err == nil {
+			err = app.destroyUserSessions(r.Context(), user.ID)
+		}
+	case models.AdminUnsuspend:
+		err = app.users.SetSuspended(user.ID, false)
+	case models.AdminResetSessions:
+		err = app.destroyUserSessions(r.Context(), user.ID)
+	case models.AdminUnlock:
+		if app.loginThrottle.Reset(loginThrottleKey(user.Email)) {
+			app.logger.Info("account lockout cleared", "email", loginThrottleKey(user.Email), "admin", app.authenticatedUser(r).ID)
+		}
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	adminID := app.authenticatedUser(r).ID
+
+	err = app.moderation.Record(adminID, action, 0, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.Info("admin action", "admin", adminID, "action", action, "user", user.ID)
+
+	// Resetting your own sessions includes the current one. Destroy it here
+	// too, otherwise LoadAndSave would write it back to the store.
+	if user.ID == adminID {
+		err = app.sessionManager.Destroy(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Done!")
+	http.Redirect(w, r, "/admin/users/", http.StatusSeeOther)
+}
+
+// adminTargetSnippet reads the snippet ID and action from the URL of an admin
+// snippet action. It writes an error response and returns false if either is
+// invalid.
+func (app *application) adminTargetSnippet(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 || r.PathValue("action") != models.AdminDeleteSnippet {
+		http.NotFound(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) adminSnippetAction(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminTargetSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Confirm = adminConfirmation{
+		Message: fmt.Sprintf("Permanently delete snippet #%d?", id),
+		Action:  r.URL.Path,
+		Cancel:  "/admin/snippets/",
+	}
+
+	app.render(w, r, http.StatusOK, "admin-confirm.tmpl.html", data)
+}
+
+func (app *application) adminSnippetActionPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminTargetSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form adminConfirmForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !form.Confirm {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.Delete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	adminID := app.authenticatedUser(r).ID
+
+	err = app.moderation.Record(adminID, models.AdminDeleteSnippet, id, 0)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.Info("admin action", "admin", adminID, "action", models.AdminDeleteSnippet, "snippet", id)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted!")
+	http.Redirect(w, r, "/admin/snippets/", http.StatusSeeOther)
+}
+
+func (app *application) userVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	id, email, err := app.parseVerificationToken(r.PathValue("token"))
+	if err != nil {
+		app.sessionManager.Put(r.Context(), "flash", "That verification link is invalid or has expired.")
+		http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.VerifyEmail(id, email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			// The account is gone or its email address has changed since
+			// the link was sent.
+			app.sessionManager.Put(r.Context(), "flash", "That verification link is invalid or has expired.")
+			http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, your email address has been verified!")
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+// verificationResendInterval limits how often a user can ask for another
+// verification email.
+const verificationResendInterval = time.Minute
+
+type accountEmailForm struct {
+	NewEmail            string `form:"newEmail"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountEmail(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountEmailForm{}
+	app.render(w, r, http.StatusOK, "account-email.tmpl.html", data)
+}
+
+func (app *application) accountEmailPost(w http.ResponseWriter, r *http.Request) {
+	var form accountEmailForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	form.NewEmail = strings.TrimSpace(form.NewEmail)
+
+	form.CheckField(validator.NotBlank(form.NewEmail), "newEmail", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.NewEmail, validator.EmailRX), "newEmail", "This field must be a valid email address")
+	form.CheckField(form.NewEmail != user.Email, "newEmail", "This is already your email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	status := http.StatusUnprocessableEntity
+
+	// The password is checked against the same limits as at login, so a
+	// session left logged in can't be used to guess it.
+	if form.Valid() && !app.loginAllowed(r, user.Email) {
+		form.AddFieldError("password", "Too many failed attempts. Please wait a while and try again.")
+		status = http.StatusTooManyRequests
+	}
+
+	if form.Valid() {
+		_, err = app.users.Authenticate(user.Email, form.Password)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.loginFailed(r, user.Email)
+			app.audit(r, user.ID, models.AuditLoginFailed, "wrong password when changing email")
+			form.AddFieldError("password", "Password is incorrect")
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if form.Valid() {
+		_, err = app.users.GetByEmail(form.NewEmail)
+		if err == nil {
+			form.AddFieldError("newEmail", "Email address is already in use")
+		} else if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		form.Password = ""
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, status, "account-email.tmpl.html", data)
+		return
+	}
+
+	err = app.sendEmailChangeEmails(user, form.NewEmail)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a confirmation link to %s. Your email address won't change until you open it.", form.NewEmail))
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+func (app *application) userConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	id, oldEmail, newEmail, err := app.parseEmailChangeToken(r.PathValue("token"))
+	if err != nil {
+		app.sessionManager.Put(r.Context(), "flash", "That confirmation link is invalid or has expired.")
+		http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.ChangeEmail(id, oldEmail, newEmail)
+	switch {
+	case err == nil:
+		app.audit(r, id, models.AuditEmailChange, fmt.Sprintf("from %s to %s", oldEmail, newEmail))
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your email address has been changed to %s.", newEmail))
+	case errors.Is(err, models.ErrDuplicateEmail):
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s is now used by another account, so your email address hasn't been changed.", newEmail))
+	case errors.Is(err, models.ErrNoRecord):
+		// The link has been used already, or the address has changed some
+		// other way since it was sent.
+		app.sessionManager.Put(r.Context(), "flash", "That confirmation link is invalid or has expired.")
+	default:
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+func (app *application) accountVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	if user.EmailVerified {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified.")
+		http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+		return
+	}
+
+	// Stored as a Unix timestamp because the session codec can't encode a
+	// time.Time held in an interface value.
+	lastSent := app.sessionManager.GetInt64(r.Context(), string(verificationSentAtSessionKey))
+	if time.Since(time.Unix(lastSent, 0)) < verificationResendInterval {
+		app.sessionManager.Put(r.Context(), "flash", "A verification email was sent very recently. Please wait a minute before asking again.")
+		http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+		return
+	}
+
+	err := app.sendVerificationEmail(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), string(verificationSentAtSessionKey), time.Now().Unix())
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("A new verification email has been sent to %s.", user.Email))
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+func ping(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+func (app *application) about(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	app.render(w, r, http.StatusOK, "about.tmpl.html", data)
+}
+
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+
+	user, err := app.users.Get(userID)
+
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "user/login/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	events, err := app.auditEvents.ForUser(user.ID, accountAuditEvents)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Form = accountAvatarForm{}
+	data.AuditEvents = events
+	if app.userInvites {
+		data.Invites.Path = invitesPath(false)
+	}
+
+	app.render(w, r, http.StatusOK, "account-view.tmpl.html", data)
+}
+
+type accountAvatarForm struct {
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountAvatarPost(w http.ResponseWriter, r *http.Request) {
+	var form accountAvatarForm
+
+	err := app.decodeMultipartForm(r, &form, maxAvatarSize)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+
+	var thumbnails map[int][]byte
+
+	file, header, err := r.FormFile("avatar")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		form.AddFieldError("avatar", "Please choose a picture to upload")
+	case err != nil:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case header.Size > maxAvatarSize:
+		file.Close()
+		form.AddFieldError("avatar", fmt.Sprintf("The picture cannot be larger than %d MB", maxAvatarSize>>20))
+	default:
+		defer file.Close()
+
+		buf, err := io.ReadAll(io.LimitReader(file, maxAvatarSize))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		thumbnails, err = avatar.Thumbnails(buf)
+		switch {
+		case errors.Is(err, avatar.ErrFormat):
+			form.AddFieldError("avatar", "The picture must be a PNG, JPEG or GIF image")
+		case errors.Is(err, avatar.ErrDimensions):
+			form.AddFieldError("avatar", fmt.Sprintf("The picture cannot be wider or taller than %d pixels", avatar.MaxDimension))
+		case err != nil:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	user := app.authenticatedUser(r)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.User = user
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "account-view.tmpl.html", data)
+		return
+	}
+
+	err = app.avatars.Set(user.ID, thumbnails)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your profile picture has been updated.")
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+func (app *application) accountAvatarDeletePost(w http.ResponseWriter, r *http.Request) {
+	err := app.avatars.Delete(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your profile picture has been removed.")
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+// avatarImage serves a user's avatar. The version in the URL is only there
+// to change the URL when the picture changes; requests for an old version
+// are redirected to the current one.
+func (app *application) avatarImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	size, err := strconv.Atoi(r.PathValue("size"))
+	if err != nil || !avatar.ValidSize(size) {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
 
-	// Remove the authenticatedUserID from the session data so that the user is
-	// 'logged out'.
-	app.sessionManager.Remove(r.Context(), string(authenticatedUserIDSessionKey))
+	if version != user.AvatarVersion {
+		http.Redirect(w, r, avatarURL(user, size), http.StatusFound)
+		return
+	}
 
-	// Add a flash message to the session to confirm to the user that they've been
-	// logged out.
-	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+	var image []byte
+	if user.HasAvatar {
+		image, err = app.avatars.Get(user.ID, size)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
-	// Redirect the user to the application home page.
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	if image == nil {
+		image, err = avatar.Identicon(strconv.Itoa(user.ID), size)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", avatarCacheControl)
+	w.Write(image)
 }
 
-func ping(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("OK"))
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	// The page size comes from the preferences of whoever is looking, which
+	// the template data already holds.
+	data := app.newTemplateData(r)
+	perPage := data.Preferences.PageSize
+
+	// Ask for one snippet more than fits on the page to find out whether
+	// there's a next page.
+	snippets, err := app.snippets.ByUser(user.ID, perPage+1, (page-1)*perPage)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var pages pageLinks
+	if page > 1 {
+		pages.Prev = page - 1
+	}
+	if len(snippets) > perPage {
+		snippets = snippets[:perPage]
+		pages.Next = page + 1
+	}
+
+	follows, err := app.followDetails(r, user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.User = user
+	data.Snippets = snippets
+	data.Pages = pages
+	data.Follows = follows
+
+	app.render(w, r, http.StatusOK, "profile.tmpl.html", data)
 }
 
-func (app *application) about(w http.ResponseWriter, r *http.Request) {
+func (app *application) userFollowing(w http.ResponseWriter, r *http.Request) {
+	app.renderFollowList(w, r, followListFollowing)
+}
+
+func (app *application) userFollowers(w http.ResponseWriter, r *http.Request) {
+	app.renderFollowList(w, r, followListFollowers)
+}
+
+// renderFollowList shows a page of the people a user follows, or of the
+// people who follow them.
+func (app *application) renderFollowList(w http.ResponseWriter, r *http.Request, list string) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	before, ok := parseBefore(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	listFollows := app.follows.Following
+	if list == followListFollowers {
+		listFollows = app.follows.Followers
+	}
+
+	users, err := listFollows(user.ID, before, followListPageSize+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	cursor := cursorLinks{Before: before}
+	if len(users) > followListPageSize {
+		users = users[:followListPageSize]
+		cursor.Next = users[followListPageSize-1].ID
+	}
+
+	follows, err := app.followDetails(r, user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	follows.List = list
+
 	data := app.newTemplateData(r)
-	app.render(w, r, http.StatusOK, "about.tmpl.html", data)
+	data.User = user
+	data.Users = users
+	data.Follows = follows
+	data.Cursor = cursor
+
+	app.render(w, r, http.StatusOK, "follows.tmpl.html", data)
 }
 
-func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
-	userID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+func (app *application) userFollowPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
 
-	user, err := app.users.Get(userID)
+	id := app.authenticatedUser(r).ID
+	if id == user.ID {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.follows.Follow(id, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You're now following @%s.", user.Username))
+
+	http.Redirect(w, r, fmt.Sprintf("/u/%s/", user.Username), http.StatusSeeOther)
+}
+
+func (app *application) userUnfollowPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.follows.Unfollow(app.authenticatedUser(r).ID, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You're no longer following @%s.", user.Username))
+
+	http.Redirect(w, r, fmt.Sprintf("/u/%s/", user.Username), http.StatusSeeOther)
+}
+
+type accountProfileForm struct {
+	Username            string `form:"username"`
+	Bio                 string `form:"bio"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountProfile(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	data := app.newTemplateData(r)
+	data.Form = accountProfileForm{Username: user.Username, Bio: user.Bio}
+
+	app.render(w, r, http.StatusOK, "account-profile.tmpl.html", data)
+}
+
+func (app *application) accountProfilePost(w http.ResponseWriter, r *http.Request) {
+	var form accountProfileForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Username = normalizeUsername(form.Username)
+	form.Bio = strings.TrimSpace(form.Bio)
+
+	checkUsername(&form.Validator, form.Username)
+	form.CheckField(validator.MaxChars(form.Bio, maxBioLength), "bio", "This field cannot be more than 500 characters long")
+
+	if form.Valid() {
+		userID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+
+		err = app.users.UpdateProfile(userID, form.Username, form.Bio)
+		if err == nil {
+			app.sessionManager.Put(r.Context(), "flash", "Your profile has been updated.")
+			http.Redirect(w, r, "/u/"+form.Username+"/", http.StatusSeeOther)
+			return
+		} else if errors.Is(err, models.ErrDuplicateUsername) {
+			form.AddFieldError("username", "Username is already taken")
+		} else {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "account-profile.tmpl.html", data)
+}
+
+func (app *application) accountNotifications(w http.ResponseWriter, r *http.Request) {
+	notifications, err := app.notifications.ForUser(app.authenticatedUser(r).ID, accountNotifications)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Notifications = notifications
+
+	app.render(w, r, http.StatusOK, "account-notifications.tmpl.html", data)
+}
+
+func (app *application) accountNotificationReadPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
 
+	err = app.notifications.MarkRead(app.authenticatedUser(r).ID, id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			http.Redirect(w, r, "user/login/", http.StatusSeeOther)
+			http.NotFound(w, r)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	http.Redirect(w, r, "/account/notifications/", http.StatusSeeOther)
+}
+
+func (app *application) accountNotificationsReadAllPost(w http.ResponseWriter, r *http.Request) {
+	err := app.notifications.MarkAllRead(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "All notifications have been marked as read.")
+	http.Redirect(w, r, "/account/notifications/", http.StatusSeeOther)
+}
+
+type accountPreferencesForm struct {
+	Timezone            string `form:"timezone"`
+	DefaultExpires      int    `form:"defaultExpires"`
+	Theme               string `form:"theme"`
+	PageSize            int    `form:"pageSize"`
+	Digest              string `form:"digest"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountPreferences(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.User = user
+	data.Form = accountPreferencesForm{
+		Timezone:       data.Preferences.Timezone,
+		DefaultExpires: data.Preferences.DefaultExpires,
+		Theme:          data.Preferences.Theme,
+		PageSize:       data.Preferences.PageSize,
+		Digest:         data.Preferences.Digest,
+	}
+	data.PreferenceChoices = newPreferenceChoices()
 
-	app.render(w, r, http.StatusOK, "account-view.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "account-preferences.tmpl.html", data)
+}
+
+func (app *application) accountPreferencesPost(w http.ResponseWriter, r *http.Request) {
+	var form accountPreferencesForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Timezone = strings.TrimSpace(form.Timezone)
+
+	form.CheckField(validator.Timezone(form.Timezone), "timezone", "This field must be a time zone name, like Europe/Paris")
+	form.CheckField(validator.PermittedValue(form.DefaultExpires, snippetExpiryDays...), "defaultExpires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.PermittedValue(form.Theme, models.Themes...), "theme", "Choose from the listed themes")
+	form.CheckField(validator.PermittedValue(form.PageSize, preferencePageSizes...), "pageSize", "Choose from the listed page sizes")
+	form.CheckField(validator.PermittedValue(form.Digest, models.Digests...), "digest", "Choose from the listed options")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.PreferenceChoices = newPreferenceChoices()
+		app.render(w, r, http.StatusUnprocessableEntity, "account-preferences.tmpl.html", data)
+		return
+	}
+
+	err = app.preferences.Update(app.authenticatedUser(r).ID, models.Preferences{
+		Timezone:       form.Timezone,
+		DefaultExpires: form.DefaultExpires,
+		Theme:          form.Theme,
+		PageSize:       form.PageSize,
+		Digest:         form.Digest,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your preferences have been saved.")
+	http.Redirect(w, r, "/account/preferences/", http.StatusSeeOther)
 }
 
 type userChangePasswordForm struct {
@@ -302,9 +2717,15 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 	form.CheckField(validator.NotBlank(form.ConfirmPassword), "confirmPassword", "This field cannot be blank")
 	form.CheckField(validator.PermittedValue(form.ConfirmPassword, form.NewPassword), "confirmPassword", "New password and confirm password is not the same")
 	form.CheckField(validator.MinChars(form.CurrentPassword, 8), "currentPassword", "This field must be at least 8 characters long")
-	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
 	form.CheckField(validator.MinChars(form.ConfirmPassword, 8), "confirmPassword", "This field must be at least 8 characters long")
 
+	user := app.authenticatedUser(r)
+	err = app.checkNewPassword(&form.Validator, "newPassword", form.NewPassword, user.Name, user.Username, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		// show errors only
@@ -330,27 +2751,104 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 			}
 
 			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
-			return
 		} else {
 			app.serverError(w, r, err)
 		}
+		return
+	}
+
+	// Anyone who knew the old password may still be logged in somewhere
+	// else, so log out every other session.
+	currentID := app.sessionManager.GetString(r.Context(), string(sessionIDSessionKey))
+	err = app.destroyOtherSessions(r.Context(), userID, currentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Use the RenewToken() method on the current session to change the session
+	// ID again.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, userID, models.AuditPasswordChange, "")
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been changed and your other sessions have been logged out.")
+	http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+}
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{Snippets: deletionKeepSnippets}
+	data.DeleteAfter = time.Now().Add(app.deletionGracePeriod)
+
+	app.render(w, r, http.StatusOK, "account-delete.tmpl.html", data)
+}
+
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Snippets, deletionKeepSnippets, deletionDeleteSnippets), "snippets", "Choose what should happen to your snippets")
+
+	renderForm := func() {
+		data := app.newTemplateData(r)
+		form.Password = ""
+		data.Form = form
+		data.DeleteAfter = time.Now().Add(app.deletionGracePeriod)
+		app.render(w, r, http.StatusUnprocessableEntity, "account-delete.tmpl.html", data)
+	}
+
+	if !form.Valid() {
+		renderForm()
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), string(authenticatedUserIDSessionKey))
+	deleteAfter := time.Now().Add(app.deletionGracePeriod)
+
+	err = app.users.ScheduleDeletion(userID, form.Password, app.deletionGracePeriod, form.Snippets == deletionDeleteSnippets)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is incorrect")
+			renderForm()
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
 	}
 
-	app.userLogoutPost(w, r)
-	// // Use the RenewToken() method on the current session to change the session
-	// // ID again.
-	// err = app.sessionManager.RenewToken(r.Context())
-	// if err != nil {
-	// 	app.serverError(w, r, err)
-	// }
+	// Log the user out everywhere, including here. Logging in again cancels
+	// the deletion.
+	err = app.destroyUserSessions(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	// // Remove the authenticatedUserID from the session data so that the user is
-	// // 'logged out'.
-	// app.sessionManager.Remove(r.Context(), string(authenticatedUserIDSessionKey))
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	// // Add a flash message to the session to confirm to the user that they've been
-	// // logged out.
-	// app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+	app.sessionManager.Remove(r.Context(), string(authenticatedUserIDSessionKey))
 
-	// http.Redirect(w, r, "/user/login/", http.StatusSeeOther)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your account will be deleted on %s. Log in before then if you change your mind.", humanDate(deleteAfter, app.userPreferences(r).Location())))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,6 +11,9 @@ import (
 
 	"github.com/go-playground/form"
 	"github.com/justinas/nosurf"
+	"github.com/markponce/snippetbox/internal/langdetect"
+	"github.com/markponce/snippetbox/internal/models"
+	"github.com/markponce/snippetbox/internal/validator"
 )
 
 // 500 error and logger
@@ -37,12 +41,31 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 }
 
 func (app *application) newTemplateData(r *http.Request) templateData {
-	return templateData{
+	data := templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
+		IsModerator:     app.authenticatedUser(r).IsModerator(),
+		IsAdmin:         app.authenticatedUser(r).IsAdmin(),
 		CSRFToken:       nosurf.Token(r),
+		Languages:       langdetect.Languages,
+		Preferences:     app.userPreferences(r),
 	}
+	data.Location = data.Preferences.Location()
+
+	if data.IsAuthenticated {
+		unread, err := app.notifications.Unread(app.authenticatedUser(r).ID)
+		if err != nil {
+			app.logger.Error("counting notifications", "error", err.Error(), "uri", r.URL.RequestURI())
+		}
+		data.UnreadNotifications = unread
+	}
+
+	if app.oidc != nil {
+		data.SSOName = app.oidc.Name
+	}
+
+	return data
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
@@ -100,6 +123,21 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// decodeMultipartForm is decodePostForm for multipart/form-data forms, which
+// are used to upload files. ParseForm() doesn't read them, so the body is
+// parsed first to get the regular form values into r.PostForm.
+// ParseMultipartForm() spools anything over maxMemory to disk, so routes
+// which use this need the limitBody middleware too. A body which goes over
+// that limit returns an *http.MaxBytesError.
+func (app *application) decodeMultipartForm(r *http.Request, dst any, maxMemory int64) error {
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		return err
+	}
+
+	return app.decodePostForm(r, dst)
+}
+
 // Return true if the current request is from an authenticated user, otherwise
 // return false.
 func (app *application) isAuthenticated(r *http.Request) bool {
@@ -109,3 +147,38 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// authenticatedUser returns the user added to the request context by the
+// authenticate middleware, or the zero User if the request isn't
+// authenticated.
+func (app *application) authenticatedUser(r *http.Request) models.User {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(models.User)
+	if !ok {
+		return models.User{}
+	}
+	return user
+}
+
+// destroyUserSessions deletes every stored session which belongs to the given
+// user, logging them out everywhere.
+func (app *application) destroyUserSessions(ctx context.Context, userID int) error {
+	return app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, string(authenticatedUserIDSessionKey)) != userID {
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+}
+
+// checkNewPassword applies the password policy to a new password and adds
+// a field error under key if it's rejected. personal holds the user's name,
+// username and email address, which the password mustn't contain.
+func (app *application) checkNewPassword(v *validator.Validator, key, password string, personal ...string) error {
+	message, err := app.passwordPolicy.Check(password, personal...)
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(message == "", key, message)
+	return nil
+}
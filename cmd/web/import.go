@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/markponce/snippetbox/internal/langdetect"
+)
+
+const (
+	// Upper bound for the uploaded archive itself.
+	maxImportSize = 10 << 20
+	// Upper bound for a single file inside a zip archive. Anything bigger is
+	// rejected instead of being read into memory.
+	maxImportFileSize = 1 << 20
+	// Upper bound for all the files in a zip archive together, once
+	// decompressed.
+	maxImportTotalSize = 10 << 20
+	// Upper bound for the number of snippets in one import.
+	maxImportEntries = 100
+	// Snippet content is stored in a TEXT column, which holds at most this
+	// many bytes.
+	maxSnippetContentSize = 65535
+)
+
+var errImportFormat = errors.New("the file must be a zip archive or a snippetbox JSON manifest")
+
+// importEntry is a single snippet read from an uploaded archive. Name is used
+// to identify the entry on the report page (a file name for zip archives, or
+// the position in the manifest for JSON).
+type importEntry struct {
+	Name     string `json:"-"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Expires  int    `json:"expires"`
+	Language string `json:"language"`
+	// LanguageConfidence is set when the language was guessed from the file
+	// extension.
+	LanguageConfidence float64 `json:"-"`
+	// Problem is set when the entry could not be read at all, e.g. a file
+	// that is too large. Such entries are rejected without validation.
+	Problem string `json:"-"`
+}
+
+// importManifest is the JSON format accepted by the import:
+//
+//	{"snippets": [{"title": "...", "content": "...", "expires": 7, "language": "go"}]}
+type importManifest struct {
+	Snippets []importEntry `json:"snippets"`
+}
+
+// importResult describes what happened to a single entry.
+type importResult struct {
+	Name    string
+	Title   string
+	ID      int
+	Reasons []string
+}
+
+// importReport is displayed after an import has been processed.
+type importReport struct {
+	Created  []importResult
+	Rejected []importResult
+}
+
+// parseImportArchive reads the uploaded file and returns one entry per
+// snippet. Zip archives produce one entry per regular file, using the file
+// name as the title. Entries that don't specify an expiry get defaultExpires.
+func parseImportArchive(data []byte, defaultExpires int) ([]importEntry, error) {
+	var (
+		entries []importEntry
+		err     error
+	)
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		entries, err = parseImportZip(data, defaultExpires)
+	} else {
+		entries, err = parseImportManifest(data, defaultExpires)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("the file does not contain any snippets")
+	}
+
+	if len(entries) > maxImportEntries {
+		return nil, fmt.Errorf("the file contains more than %d snippets", maxImportEntries)
+	}
+
+	return entries, nil
+}
+
+func parseImportZip(data []byte, defaultExpires int) ([]importEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errImportFormat
+	}
+
+	var files []*zip.File
+
+	for _, f := range zr.File {
+		// Skip directories and the metadata folders some archivers add.
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), ".") {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	// Count the files before decompressing any of them, so a small archive
+	// of many highly compressed files can't use up memory.
+	if len(files) > maxImportEntries {
+		return nil, fmt.Errorf("the file contains more than %d snippets", maxImportEntries)
+	}
+
+	var (
+		entries []importEntry
+		total   int
+	)
+
+	for _, f := range files {
+		guess := langdetect.FromFilename(f.Name)
+
+		entry := importEntry{
+			Name:               f.Name,
+			Title:              path.Base(f.Name),
+			Expires:            defaultExpires,
+			Language:           guess.Language,
+			LanguageConfidence: guess.Confidence,
+		}
+
+		content, err := readZipFile(f, maxImportTotalSize-total)
+		switch {
+		case errors.Is(err, errImportTooLarge):
+			return nil, err
+		case err != nil:
+			entry.Problem = err.Error()
+		default:
+			total += len(content)
+			entry.Content = content
+			entry.Problem = importContentProblem(content)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+var errImportTooLarge = fmt.Errorf("the files in the archive add up to more than %d MB", maxImportTotalSize>>20)
+
+// readZipFile decompresses a single file. remaining is how much more of the
+// archive may be decompressed; going over it returns errImportTooLarge.
+func readZipFile(f *zip.File, remaining int) (string, error) {
+	if f.UncompressedSize64 > maxImportFileSize {
+		return "", fmt.Errorf("file is larger than %d bytes", maxImportFileSize)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", errors.New("file could not be read")
+	}
+	defer rc.Close()
+
+	// Don't trust the size in the header; read at most one byte past the
+	// limit so oversized files can still be detected.
+	b, err := io.ReadAll(io.LimitReader(rc, int64(min(maxImportFileSize, remaining))+1))
+	if err != nil {
+		return "", errors.New("file could not be read")
+	}
+
+	if len(b) > maxImportFileSize {
+		return "", fmt.Errorf("file is larger than %d bytes", maxImportFileSize)
+	}
+
+	if len(b) > remaining {
+		return "", errImportTooLarge
+	}
+
+	return string(b), nil
+}
+
+// importContentProblem returns why content can't be stored as a snippet, or
+// an empty string if it can.
+func importContentProblem(content string) string {
+	switch {
+	case len(content) > maxSnippetContentSize:
+		return fmt.Sprintf("content is longer than %d bytes", maxSnippetContentSize)
+	case !utf8.ValidString(content):
+		return "content is not valid UTF-8 text"
+	default:
+		return ""
+	}
+}
+
+func parseImportManifest(data []byte, defaultExpires int) ([]importEntry, error) {
+	var manifest importManifest
+
+	err := json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, errImportFormat
+	}
+
+	for i := range manifest.Snippets {
+		manifest.Snippets[i].Name = fmt.Sprintf("#%d", i+1)
+		if manifest.Snippets[i].Expires == 0 {
+			manifest.Snippets[i].Expires = defaultExpires
+		}
+		manifest.Snippets[i].Problem = importContentProblem(manifest.Snippets[i].Content)
+	}
+
+	return manifest.Snippets, nil
+}
+
+// fieldErrorMessages flattens a FieldErrors map into a stable, readable list.
+func fieldErrorMessages(fieldErrors map[string]string) []string {
+	messages := make([]string, 0, len(fieldErrors))
+	for field, message := range fieldErrors {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, message))
+	}
+	sort.Strings(messages)
+	return messages
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func newTestZip(t *testing.T, files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for name, content := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestParseImportArchive(t *testing.T) {
+	t.Run("Zip", func(t *testing.T) {
+		data := newTestZip(t, map[string]string{
+			"haiku/pond.txt":  "An old silent pond...",
+			"__MACOSX/._pond": "junk",
+			"haiku/.DS_Store": "junk",
+			"haiku/":          "",
+		})
+
+		entries, err := parseImportArchive(data, 7)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 1)
+		assert.Equal(t, entries[0].Name, "haiku/pond.txt")
+		assert.Equal(t, entries[0].Title, "pond.txt")
+		assert.Equal(t, entries[0].Content, "An old silent pond...")
+		assert.Equal(t, entries[0].Expires, 7)
+	})
+
+	t.Run("Unstorable content", func(t *testing.T) {
+		data := newTestZip(t, map[string]string{
+			"long.txt":   strings.Repeat("a", maxSnippetContentSize+1),
+			"binary.bin": "\xff\xfe\x00",
+		})
+
+		entries, err := parseImportArchive(data, 7)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 2)
+
+		problems := map[string]string{}
+		for _, e := range entries {
+			problems[e.Name] = e.Problem
+		}
+		assert.Equal(t, problems["long.txt"], "content is longer than 65535 bytes")
+		assert.Equal(t, problems["binary.bin"], "content is not valid UTF-8 text")
+	})
+
+	t.Run("Too many files", func(t *testing.T) {
+		files := map[string]string{}
+		for i := range maxImportEntries + 1 {
+			files[fmt.Sprintf("%d.txt", i)] = "content"
+		}
+
+		_, err := parseImportArchive(newTestZip(t, files), 7)
+		if err == nil {
+			t.Error("got: nil; expected an error")
+		}
+	})
+
+	t.Run("Too large once decompressed", func(t *testing.T) {
+		files := map[string]string{}
+		for i := range maxImportTotalSize/maxImportFileSize + 1 {
+			files[fmt.Sprintf("%d.txt", i)] = strings.Repeat("a", maxImportFileSize)
+		}
+
+		_, err := parseImportArchive(newTestZip(t, files), 7)
+		assert.Equal(t, err, errImportTooLarge)
+	})
+
+	t.Run("Manifest", func(t *testing.T) {
+		data := []byte(`{"snippets": [
+			{"title": "First", "content": "one", "expires": 1},
+			{"title": "Second", "content": "two"}
+		]}`)
+
+		entries, err := parseImportArchive(data, 365)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 2)
+		assert.Equal(t, entries[0].Name, "#1")
+		assert.Equal(t, entries[0].Expires, 1)
+		assert.Equal(t, entries[1].Name, "#2")
+		assert.Equal(t, entries[1].Expires, 365)
+		assert.Equal(t, entries[1].Problem, "")
+	})
+
+	t.Run("Manifest with long content", func(t *testing.T) {
+		data := fmt.Appendf(nil, `{"snippets": [{"title": "Long", "content": %q}]}`, strings.Repeat("a", maxSnippetContentSize+1))
+
+		entries, err := parseImportArchive(data, 365)
+		assert.NilError(t, err)
+		assert.Equal(t, entries[0].Problem, "content is longer than 65535 bytes")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parseImportArchive([]byte("not an archive"), 365)
+		assert.Equal(t, err, errImportFormat)
+	})
+
+	t.Run("Empty manifest", func(t *testing.T) {
+		_, err := parseImportArchive([]byte(`{"snippets": []}`), 365)
+		if err == nil {
+			t.Error("got: nil; expected an error")
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// Server policies for who can sign up.
+const (
+	// Anyone can sign up.
+	signupModeOpen = "open"
+	// Signing up needs an invite code from an admin, or from any user if
+	// -user-invites is set.
+	signupModeInvite = "invite"
+)
+
+// inviteMaxUses is the most signups a single invite code can be used for.
+const inviteMaxUses = 100
+
+// inviteExpiryDays are the lifetimes users can choose from when they create
+// an invite code.
+var inviteExpiryDays = []int{1, 7, 30, 90}
+
+// invitesPage holds what the invites page shows besides the form. The same
+// page is used by admins, who see everyone's invites, and by users, who only
+// see their own.
+type invitesPage struct {
+	Invites []models.Invite
+	// New is the code of an invite which has just been created.
+	New string
+	// Path is where the page lives, either under /admin/ or /account/.
+	Path       string
+	SignupURL  string
+	ExpiryDays []int
+	MaxUses    int
+	Admin      bool
+}
+
+// inviteExpires returns when an invite created now with the given lifetime
+// expires.
+func inviteExpires(days int) time.Time {
+	return time.Now().AddDate(0, 0, days)
+}
+
+// invitesPath returns the path of the admin or the user invites page.
+func invitesPath(admin bool) string {
+	if admin {
+		return "/admin/invites/"
+	}
+	return "/account/invites/"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+const loginLinkTTL = 15 * time.Minute
+
+// loginLinkNonce returns the nonce which binds login links to this browser's
+// session, creating it if the browser hasn't asked for a link before. It's
+// reused for every link, so asking for another link doesn't break the ones
+// already sent.
+func (app *application) loginLinkNonce(r *http.Request) string {
+	nonce := app.sessionManager.GetString(r.Context(), string(loginLinkNonceSessionKey))
+	if nonce == "" {
+		nonce = rand.Text()
+		app.sessionManager.Put(r.Context(), string(loginLinkNonceSessionKey), nonce)
+	}
+	return nonce
+}
+
+// sendLoginLinkEmail creates a one-time login link for the user, bound to
+// the nonce in the session, and emails it to them.
+func (app *application) sendLoginLinkEmail(user models.User, nonce string) error {
+	token, err := app.loginLinks.New(user.ID, nonce, loginLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"Name":      user.Name,
+		"URL":       fmt.Sprintf("%s/user/login/link/%s/", app.baseURL, url.PathEscape(token)),
+		"ExpiresIn": "15 minutes",
+	}
+
+	return app.sendEmail(user.Email, "login-link.tmpl", data)
+}
+
+// loginLinkFailed sends the user back to the page for asking for a login link
+// with a message.
+func (app *application) loginLinkFailed(w http.ResponseWriter, r *http.Request, message string) {
+	app.sessionManager.Put(r.Context(), "flash", message)
+	http.Redirect(w, r, "/user/login/link/", http.StatusSeeOther)
+}
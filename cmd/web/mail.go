@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+
+	"github.com/markponce/snippetbox/internal/mailer"
+	"github.com/markponce/snippetbox/ui"
+)
+
+// sendEmail renders one of the templates in ui/email and sends it. Each
+// template defines a "subject", a "plainBody" and an "htmlBody" block.
+func (app *application) sendEmail(recipient, templateFile string, data any) error {
+	patterns := []string{"email/" + templateFile}
+
+	ts, err := template.New("email").ParseFS(ui.Files, patterns...)
+	if err != nil {
+		return err
+	}
+
+	subject := new(bytes.Buffer)
+	err = ts.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = ts.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+
+	// The HTML body is rendered with html/template so that values like the
+	// user's name are escaped.
+	hts, err := htmltemplate.New("email").ParseFS(ui.Files, patterns...)
+	if err != nil {
+		return err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = hts.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(mailer.Message{
+		To:      recipient,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(plainBody.String()) + "\n",
+		HTML:    strings.TrimSpace(htmlBody.String()) + "\n",
+	})
+}
@@ -1,20 +1,36 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
 	"flag"
+	"html/template"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
-	"text/template"
+	"slices"
+	"strings"
 	"time"
+	// Embed the time zone database, so users' time zones work even on
+	// servers which don't have one installed.
+	_ "time/tzdata"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/markponce/snippetbox/internal/events"
+	"github.com/markponce/snippetbox/internal/mailer"
 	"github.com/markponce/snippetbox/internal/models"
+	"github.com/markponce/snippetbox/internal/password"
+	"github.com/markponce/snippetbox/internal/secrets"
+	"github.com/markponce/snippetbox/internal/signer"
+	"github.com/markponce/snippetbox/internal/throttle"
+	"github.com/markponce/snippetbox/internal/validator"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type application struct {
@@ -22,16 +38,69 @@ type application struct {
 	// import via package
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	moderation     models.ModerationModelInterface
+	stats          models.StatsModelInterface
+	passwordResets models.PasswordResetModelInterface
+	twoFactor      models.TwoFactorModelInterface
+	identities     models.IdentityModelInterface
+	avatars        models.AvatarModelInterface
+	accessTokens   models.AccessTokenModelInterface
+	auditEvents    models.AuditModelInterface
+	invites        models.InviteModelInterface
+	loginLinks     models.LoginLinkModelInterface
+	preferences    models.PreferenceModelInterface
+	notifications  models.NotificationModelInterface
+	follows        models.FollowModelInterface
+	digests        models.DigestModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
-	debug          bool
+	secretScanner  *secrets.Scanner
+	passwordPolicy *validator.PasswordPolicy
+	secretsMode    string
+	signupMode     string
+	userInvites    bool
+	mailer         mailer.Mailer
+	signer         *signer.Signer
+	oidc           *oidcProvider
+	loginThrottle  *throttle.Limiter
+	ipThrottle     *throttle.Limiter
+	events         *events.Dispatcher
+	// deletionGracePeriod is how long a user has to change their mind after
+	// asking for their account to be deleted.
+	deletionGracePeriod time.Duration
+	baseURL             string
+	debug               bool
 }
 
 func main() {
 	addr := flag.String("addr", ":4000", "HTTP network address")
 	dsn := flag.String("dsn", "snippetbox:snippetbox@/snippetbox?parseTime=true", "MySQL data source name")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	secretsMode := flag.String("secrets", secretsModeWarn, "Handling of snippets that contain secrets (off|warn|reject)")
+	signupMode := flag.String("signup", signupModeOpen, "Who can sign up (open|invite)")
+	userInvites := flag.Bool("user-invites", false, "Let all users, not only admins, create invite codes")
+	baseURL := flag.String("base-url", "https://localhost:4000", "Public URL of the application, used in emailed links")
+	secretKey := flag.String("secret-key", "", "Key for signing emailed links (a random key is used if empty)")
+	breachedPasswords := flag.String("breached-passwords", "", "Directory of Pwned Passwords range files to check new passwords against (only a small bundled list is used if empty)")
+	passwordHash := flag.String("password-hash", password.Argon2id, "Algorithm for new password hashes (argon2id|bcrypt); older hashes are upgraded when users log in")
+	argon2Memory := flag.Uint("argon2-memory", uint(password.DefaultArgon2idParams.Memory), "Argon2id memory cost in KiB")
+	argon2Iterations := flag.Uint("argon2-iterations", uint(password.DefaultArgon2idParams.Iterations), "Argon2id number of iterations")
+	argon2Parallelism := flag.Uint("argon2-parallelism", uint(password.DefaultArgon2idParams.Parallelism), "Argon2id degree of parallelism")
+	bcryptCost := flag.Int("bcrypt-cost", 12, "bcrypt cost")
+	deletionGracePeriod := flag.Duration("deletion-grace-period", 14*24*time.Hour, "How long users can cancel the deletion of their account by logging in")
+
+	smtpHost := flag.String("smtp-host", "", "SMTP host (emails are written to -mail-dir or the log if empty)")
+	smtpPort := flag.Int("smtp-port", 25, "SMTP port")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpSender := flag.String("smtp-sender", "Snippetbox <no-reply@snippetbox.local>", "SMTP sender")
+	mailDir := flag.String("mail-dir", "", "Directory to write emails to instead of sending them")
+
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL (single sign-on is disabled if empty)")
+	oidcClientID := flag.String("oidc-client-id", "", "OpenID Connect client ID")
+	oidcClientSecret := flag.String("oidc-client-secret", "", "OpenID Connect client secret")
+	oidcName := flag.String("oidc-name", "SSO", "Name of the identity provider shown on the login page")
 
 	flag.Parse()
 
@@ -40,6 +109,89 @@ func main() {
 		AddSource: true,
 	}))
 
+	switch *secretsMode {
+	case secretsModeOff, secretsModeWarn, secretsModeReject:
+	default:
+		logger.Error("invalid -secrets value", "value", *secretsMode)
+		os.Exit(1)
+	}
+
+	switch *signupMode {
+	case signupModeOpen, signupModeInvite:
+	default:
+		logger.Error("invalid -signup value", "value", *signupMode)
+		os.Exit(1)
+	}
+
+	if !slices.Contains(password.Algorithms, *passwordHash) {
+		logger.Error("invalid -password-hash value", "value", *passwordHash)
+		os.Exit(1)
+	}
+
+	// The hash parameters are narrowed to smaller types below, so check them
+	// first rather than letting a bad value wrap around to zero.
+	if *argon2Iterations < 1 || *argon2Iterations > math.MaxUint32 {
+		logger.Error("invalid -argon2-iterations value; must be at least 1", "value", *argon2Iterations)
+		os.Exit(1)
+	}
+
+	if *argon2Parallelism < 1 || *argon2Parallelism > math.MaxUint8 {
+		logger.Error("invalid -argon2-parallelism value; must be between 1 and 255", "value", *argon2Parallelism)
+		os.Exit(1)
+	}
+
+	if *argon2Memory < 8**argon2Parallelism || *argon2Memory > math.MaxUint32 {
+		logger.Error("invalid -argon2-memory value; must be at least 8 KiB per degree of parallelism", "value", *argon2Memory)
+		os.Exit(1)
+	}
+
+	if *bcryptCost < bcrypt.MinCost || *bcryptCost > bcrypt.MaxCost {
+		logger.Error("invalid -bcrypt-cost value", "value", *bcryptCost, "min", bcrypt.MinCost, "max", bcrypt.MaxCost)
+		os.Exit(1)
+	}
+
+	hasher := password.NewHasher()
+	hasher.Algorithm = *passwordHash
+	hasher.Argon2id.Memory = uint32(*argon2Memory)
+	hasher.Argon2id.Iterations = uint32(*argon2Iterations)
+	hasher.Argon2id.Parallelism = uint8(*argon2Parallelism)
+	hasher.BcryptCost = *bcryptCost
+
+	key := []byte(*secretKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+		logger.Warn("no -secret-key set; emailed links will stop working when the server restarts")
+	}
+
+	var breached validator.BreachedPasswords = validator.CommonPasswords
+	if *breachedPasswords != "" {
+		breached = validator.BreachedIn{validator.CommonPasswords, validator.PrefixDir(*breachedPasswords)}
+	}
+
+	var mail mailer.Mailer
+	switch {
+	case *smtpHost != "":
+		mail = &mailer.SMTPMailer{Host: *smtpHost, Port: *smtpPort, Username: *smtpUsername, Password: *smtpPassword, Sender: *smtpSender}
+	case *mailDir != "":
+		mail = &mailer.FileMailer{Dir: *mailDir, Sender: *smtpSender}
+	default:
+		mail = &mailer.LogMailer{Logger: logger}
+	}
+
+	var provider *oidcProvider
+	if *oidcIssuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		redirectURL := strings.TrimSuffix(*baseURL, "/") + "/user/login/oidc/callback/"
+		var err error
+		provider, err = newOIDCProvider(ctx, *oidcName, *oidcIssuer, *oidcClientID, *oidcClientSecret, redirectURL)
+		cancel()
+		if err != nil {
+			logger.Error("oidc discovery failed", "issuer", *oidcIssuer, "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
 	// db call
 	db, err := openDB(*dsn)
 	if err != nil {
@@ -69,12 +221,39 @@ func main() {
 		// init logger
 		logger: logger,
 		// init db
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-		debug:          *debug,
+		snippets:            &models.SnippetModel{DB: db},
+		users:               &models.UserModel{DB: db, Hasher: hasher},
+		moderation:          &models.ModerationModel{DB: db},
+		stats:               &models.StatsModel{DB: db},
+		passwordResets:      &models.PasswordResetModel{DB: db, Hasher: hasher},
+		twoFactor:           &models.TwoFactorModel{DB: db},
+		identities:          &models.IdentityModel{DB: db},
+		avatars:             &models.AvatarModel{DB: db},
+		accessTokens:        &models.AccessTokenModel{DB: db},
+		auditEvents:         &models.AuditModel{DB: db},
+		invites:             &models.InviteModel{DB: db},
+		loginLinks:          &models.LoginLinkModel{DB: db},
+		preferences:         &models.PreferenceModel{DB: db},
+		notifications:       &models.NotificationModel{DB: db},
+		follows:             &models.FollowModel{DB: db},
+		digests:             &models.DigestModel{DB: db},
+		templateCache:       templateCache,
+		formDecoder:         formDecoder,
+		sessionManager:      sessionManager,
+		secretScanner:       secrets.NewScanner(secrets.DefaultDetectors()...),
+		passwordPolicy:      validator.NewPasswordPolicy(breached),
+		secretsMode:         *secretsMode,
+		signupMode:          *signupMode,
+		userInvites:         *userInvites,
+		mailer:              mail,
+		signer:              signer.New(key),
+		oidc:                provider,
+		loginThrottle:       throttle.New(accountThrottlePolicy),
+		ipThrottle:          throttle.New(ipThrottlePolicy),
+		events:              events.NewDispatcher(),
+		deletionGracePeriod: *deletionGracePeriod,
+		baseURL:             strings.TrimSuffix(*baseURL, "/"),
+		debug:               *debug,
 	}
 
 	tlsConfig := &tls.Config{
@@ -83,6 +262,12 @@ func main() {
 		MinVersion: tls.VersionTLS13,
 	}
 
+	app.subscribeNotifications()
+
+	go app.runAccountDeletion(context.Background(), accountDeletionInterval)
+	go app.runExpiryNotifications(context.Background(), expiryNotificationInterval)
+	go app.runDigests(context.Background(), digestInterval)
+
 	logger.Info("start server", "addr", *addr)
 
 	srv := &http.Server{
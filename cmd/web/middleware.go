@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
+	"github.com/markponce/snippetbox/internal/models"
 )
 
 func commonHeaders(next http.Handler) http.Handler {
@@ -82,6 +85,69 @@ func (app *application) requireAuthetication(next http.Handler) http.Handler {
 	})
 }
 
+// requireVerifiedEmail sends users who haven't verified their email address
+// yet back to their account page. It must come after requireAuthetication in
+// the chain.
+func (app *application) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).EmailVerified {
+			app.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets.")
+			http.Redirect(w, r, "/account/view/", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin only lets administrators through. It must come after
+// requireAuthetication in the chain.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).IsAdmin() {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireModerator only lets moderators through. It must come after
+// requireAuthetication in the chain.
+func (app *application) requireModerator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).IsModerator() {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody refuses request bodies larger than maxSize plus some room for
+// the other form fields. Uploads would otherwise be read without limit,
+// with anything that doesn't fit in memory spooled to disk. It has to come
+// before noSurf, which parses the form to find the CSRF token.
+func (app *application) limitBody(maxSize int64) func(http.Handler) http.Handler {
+	limit := maxSize + 1<<20
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				w.Header().Set("Connection", "close")
+				app.clientError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Create a NoSurf middleware function which uses a customized CSRF cookie with
 // the Secure, Path and HttpOnly attributes set.
 func noSurf(next http.Handler) http.Handler {
@@ -94,11 +160,79 @@ func noSurf(next http.Handler) http.Handler {
 		SameSite: http.SameSiteLaxMode,
 	})
 
+	// Requests authenticated with a personal access token don't carry any
+	// cookies a third-party site could abuse, so they don't need a CSRF
+	// token. Requests which only send a session cookie are always checked.
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		_, ok := r.Context().Value(accessTokenContextKey).(models.AccessToken)
+		return ok
+	})
+
 	return csrfHandler
 }
 
+// authenticateToken authenticates requests which send a personal access
+// token in an "Authorization: Bearer" header. The token must allow the given
+// scope. Requests without the header are left for authenticate to handle
+// with the session. It must come before noSurf in the chain.
+func (app *application) authenticateToken(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || token == "" {
+				app.tokenError(w, http.StatusUnauthorized, "invalid_request")
+				return
+			}
+
+			accessToken, err := app.accessTokens.Authenticate(token)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					app.tokenError(w, http.StatusUnauthorized, "invalid_token")
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+
+			user, err := app.users.Get(accessToken.UserID)
+			if err != nil && !errors.Is(err, models.ErrNoRecord) {
+				app.serverError(w, r, err)
+				return
+			}
+
+			if err != nil || user.Suspended {
+				app.tokenError(w, http.StatusUnauthorized, "invalid_token")
+				return
+			}
+
+			if !accessToken.HasScope(scope) {
+				app.tokenError(w, http.StatusForbidden, "insufficient_scope")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+			ctx = context.WithValue(ctx, accessTokenContextKey, accessToken)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests which were authenticated with an access token don't use
+		// the session.
+		if app.isAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
 
 		// Retrieve the authenticatedUserID value from the session using the
 		// GetInt() method. This will return the zero value for an int (0) if no
@@ -112,19 +246,23 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// Otherwise, we check to see if a user with that ID exists in our
 		// database.
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, r, err)
 			return
 		}
 
-		// If a matching user is found, we know that the request is
-		// coming from an authenticated user who exists in our database. We
-		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
-		if exists {
+		// If a matching user is found and they haven't been suspended, we know
+		// that the request is coming from an authenticated user who exists in
+		// our database. We create a new copy of the request (with an
+		// isAuthenticatedContextKey value of true and the user itself in the
+		// request context) and assign it to r.
+		if err == nil && !user.Suspended {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
 			r = r.WithContext(ctx)
+
+			app.touchSession(r)
 		}
 
 		// Call the next handler in the chain.
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/events"
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// Names of the events the application publishes.
+const (
+	eventReportResolved  = "report.resolved"
+	eventSnippetExpiring = "snippet.expiring"
+)
+
+// reportResolvedEvent is published when a moderator resolves a report.
+type reportResolvedEvent struct {
+	Report models.Report
+	Action string
+}
+
+func (reportResolvedEvent) EventName() string { return eventReportResolved }
+
+// snippetExpiringEvent is published when a snippet is about to expire.
+type snippetExpiringEvent struct {
+	Snippet models.Snippet
+}
+
+func (snippetExpiringEvent) EventName() string { return eventSnippetExpiring }
+
+// accountNotifications is the number of notifications shown on the
+// notifications page.
+const accountNotifications = 50
+
+// snippetExpiryNotice is how long before a snippet expires its author is
+// told about it, and expiryNotificationInterval is how often snippets are
+// checked.
+const (
+	snippetExpiryNotice        = 24 * time.Hour
+	expiryNotificationInterval = time.Hour
+)
+
+// subscribeNotifications registers the handlers which turn events into
+// notifications. A new kind of notification only needs an event type,
+// a handler here, and a call to publish where the event happens.
+func (app *application) subscribeNotifications() {
+	app.events.Subscribe(eventReportResolved, app.notifyReportResolved)
+	app.events.Subscribe(eventSnippetExpiring, app.notifySnippetExpiring)
+}
+
+// publish passes an event to its handlers. Like audit records, failures are
+// logged but never fail the request.
+func (app *application) publish(ctx context.Context, e events.Event) {
+	err := app.events.Publish(ctx, e)
+	if err != nil {
+		app.logger.Error("publishing event", "event", e.EventName(), "error", err.Error())
+	}
+}
+
+// notifyReportResolved tells the reporter what came of their report, and
+// the author if their snippet was hidden or removed.
+func (app *application) notifyReportResolved(ctx context.Context, e events.Event) error {
+	ev := e.(reportResolvedEvent)
+	report := ev.Report
+	link := fmt.Sprintf("/snippet/view/%d/", report.SnippetID)
+
+	var outcome string
+	switch ev.Action {
+	case models.ModerationHide:
+		outcome, link = "The snippet has been hidden.", ""
+	case models.ModerationDelete:
+		outcome, link = "The snippet has been removed.", ""
+	case models.ModerationSuspend:
+		outcome = "Its author has been suspended."
+	default:
+		outcome = "A moderator decided no action was needed."
+	}
+
+	err := app.notifications.Insert(models.Notification{
+		UserID:  report.ReporterID,
+		Kind:    models.NotifyReportResolved,
+		Message: fmt.Sprintf("Your report about %q has been resolved. %s", report.SnippetTitle, outcome),
+		Link:    link,
+	})
+	if err != nil {
+		return err
+	}
+
+	if report.AuthorID == 0 || (ev.Action != models.ModerationHide && ev.Action != models.ModerationDelete) {
+		return nil
+	}
+
+	what := "hidden"
+	if ev.Action == models.ModerationDelete {
+		what = "removed"
+	}
+
+	return app.notifications.Insert(models.Notification{
+		UserID:  report.AuthorID,
+		Kind:    models.NotifySnippetModerated,
+		Message: fmt.Sprintf("Your snippet %q was %s by a moderator after it was reported.", report.SnippetTitle, what),
+	})
+}
+
+// notifySnippetExpiring tells the author that their snippet expires soon.
+// The notification is keyed by snippet, so it's only sent once however often
+// the event is published.
+func (app *application) notifySnippetExpiring(ctx context.Context, e events.Event) error {
+	s := e.(snippetExpiringEvent).Snippet
+
+	return app.notifications.Insert(models.Notification{
+		UserID:  s.UserID,
+		Kind:    models.NotifySnippetExpiring,
+		Message: fmt.Sprintf("Your snippet %q expires in less than a day.", s.Title),
+		Link:    fmt.Sprintf("/snippet/view/%d/", s.ID),
+		Key:     fmt.Sprintf("snippet-expiring:%d", s.ID),
+	})
+}
+
+// publishExpiringSnippets publishes an event for every snippet which
+// expires within snippetExpiryNotice.
+func (app *application) publishExpiringSnippets(ctx context.Context) error {
+	snippets, err := app.snippets.Expiring(snippetExpiryNotice)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		app.publish(ctx, snippetExpiringEvent{Snippet: s})
+	}
+
+	return nil
+}
+
+// runExpiryNotifications calls publishExpiringSnippets every interval until
+// ctx is cancelled.
+func (app *application) runExpiryNotifications(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := app.publishExpiringSnippets(ctx)
+		if err != nil {
+			app.logger.Error("expiry notifications failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is the OpenID Connect identity provider users can log in
+// with. It's nil on the application unless an issuer has been configured.
+type oidcProvider struct {
+	// Name is shown on the login button.
+	Name     string
+	issuer   string
+	verifier *oidc.IDTokenVerifier
+	config   oauth2.Config
+}
+
+// newOIDCProvider fetches the provider's discovery document from
+// {issuer}/.well-known/openid-configuration.
+func newOIDCProvider(ctx context.Context, name, issuer, clientID, clientSecret, redirectURL string) (*oidcProvider, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcProvider{
+		Name:     name,
+		issuer:   issuer,
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+	}, nil
+}
+
+// oidcLogin holds the values which have to survive the round trip to the
+// provider. They're kept in the session.
+type oidcLogin struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+func newOIDCLogin() oidcLogin {
+	return oidcLogin{
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+}
+
+// AuthCodeURL returns the URL of the provider's login page.
+func (p *oidcProvider) AuthCodeURL(login oidcLogin) string {
+	return p.config.AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+}
+
+// oidcClaims are the ID token claims used to find the user's account.
+type oidcClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+var errOIDCNonce = errors.New("oidc: nonce does not match")
+
+// Exchange swaps the authorization code for tokens, then checks the ID
+// token's signature, issuer, audience, expiry and nonce before returning
+// its claims.
+func (p *oidcProvider) Exchange(ctx context.Context, login oidcLogin, code string) (oidcClaims, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return oidcClaims{}, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return oidcClaims{}, errors.New("oidc: no id_token in token response")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return oidcClaims{}, err
+	}
+
+	if idToken.Nonce != login.Nonce {
+		return oidcClaims{}, errOIDCNonce
+	}
+
+	var claims oidcClaims
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return oidcClaims{}, fmt.Errorf("oidc: %w", err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/assert"
+	"github.com/markponce/snippetbox/internal/models/mocks"
+)
+
+const testOIDCClientID = "snippetbox"
+
+// fakeOIDCProvider is a minimal in-process OpenID Connect provider. Instead
+// of showing a login page, tests call authorize() with the claims the user
+// should have.
+type fakeOIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// signingKey signs ID tokens. It's the same as key unless a test wants
+	// a token with a bad signature.
+	signingKey *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeOIDCGrant
+}
+
+type fakeOIDCGrant struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &fakeOIDCProvider{key: key, signingKey: key, grants: make(map[string]fakeOIDCGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (p *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token swaps a code for an ID token, checking the PKCE verifier against
+// the challenge sent to authorize().
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(grant.claims),
+	})
+}
+
+func (p *fakeOIDCProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.signingKey, crypto.SHA256, hash[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// authorize plays the part of the provider's login page. It checks the
+// request sent by the application and returns the query string for the
+// callback. The ID token will contain the given claims, along with the
+// standard ones.
+func (p *fakeOIDCProvider) authorize(t *testing.T, authURL string, claims map[string]any) url.Values {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	assert.Equal(t, u.Path, "/authorize")
+	assert.Equal(t, q.Get("client_id"), testOIDCClientID)
+	assert.Equal(t, q.Get("response_type"), "code")
+	assert.Equal(t, q.Get("code_challenge_method"), "S256")
+
+	idClaims := map[string]any{
+		"iss":   p.URL,
+		"aud":   testOIDCClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		idClaims[k] = v
+	}
+
+	code := rand.Text()
+
+	p.mu.Lock()
+	p.grants[code] = fakeOIDCGrant{challenge: q.Get("code_challenge"), claims: idClaims}
+	p.mu.Unlock()
+
+	return url.Values{"code": {code}, "state": {q.Get("state")}}
+}
+
+func TestUserLoginOIDC(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Not configured", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/user/login/oidc/")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	provider := newFakeOIDCProvider(t)
+	defer provider.Close()
+
+	var err error
+	app.oidc, err = newOIDCProvider(context.Background(), "Example SSO", provider.URL, testOIDCClientID, "secret", "https://snippetbox.test/user/login/oidc/callback/")
+	assert.NilError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name         string
+		claims       map[string]any
+		tamper       func(q url.Values)
+		badSignature bool
+		wantLocation string
+		wantFlash    string
+		wantEmail    string
+	}{
+		{
+			name:         "Link by verified email",
+			claims:       map[string]any{"sub": "alice-sub", "email": "alice@example.com", "email_verified": true},
+			wantLocation: "/",
+			wantEmail:    "alice@example.com",
+		},
+		{
+			name:         "Already linked",
+			claims:       map[string]any{"sub": mocks.LinkedSubject, "email": "bob@elsewhere.example", "email_verified": true},
+			wantLocation: "/",
+			wantEmail:    "bob@example.com",
+		},
+		{
+			name:         "Linked to a deleted account",
+			claims:       map[string]any{"sub": mocks.DeletedUserSubject, "email": "gone@example.com", "email_verified": true},
+			wantLocation: "/user/login/",
+			wantFlash:    "There&#39;s no account linked to your Example SSO login",
+		},
+		{
+			name:         "Two-factor user",
+			claims:       map[string]any{"sub": "erin-sub", "email": "erin@example.com", "email_verified": true},
+			wantLocation: "/user/login/2fa/",
+		},
+		{
+			name:         "Email not verified by provider",
+			claims:       map[string]any{"sub": "alice-sub", "email": "alice@example.com", "email_verified": false},
+			wantLocation: "/user/login/",
+			wantFlash:    "Example SSO hasn&#39;t verified your email address",
+		},
+		{
+			name:         "Email not verified with us",
+			claims:       map[string]any{"sub": "dave-sub", "email": "dave@example.com", "email_verified": true},
+			wantLocation: "/user/login/",
+			wantFlash:    "Please verify your email address",
+		},
+		{
+			name:         "No account",
+			claims:       map[string]any{"sub": "frank-sub", "email": "frank@example.com", "email_verified": true},
+			wantLocation: "/user/login/",
+			wantFlash:    "There&#39;s no account for frank@example.com",
+		},
+		{
+			name:         "Wrong state",
+			claims:       map[string]any{"sub": "alice-sub", "email": "alice@example.com", "email_verified": true},
+			tamper:       func(q url.Values) { q.Set("state", "forged") },
+			wantLocation: "/user/login/",
+			wantFlash:    "Your login has expired",
+		},
+		{
+			name:         "Wrong nonce",
+			claims:       map[string]any{"sub": "alice-sub", "email": "alice@example.com", "email_verified": true, "nonce": "replayed"},
+			wantLocation: "/user/login/",
+			wantFlash:    "We couldn&#39;t log you in with Example SSO",
+		},
+		{
+			name:         "Bad signature",
+			claims:       map[string]any{"sub": "alice-sub", "email": "alice@example.com", "email_verified": true},
+			badSignature: true,
+			wantLocation: "/user/login/",
+			wantFlash:    "We couldn&#39;t log you in with Example SSO",
+		},
+		{
+			name:   "Cancelled at provider",
+			claims: map[string]any{},
+			tamper: func(q url.Values) {
+				q.Del("code")
+				q.Set("error", "access_denied")
+			},
+			wantLocation: "/user/login/",
+			wantFlash:    "Login with Example SSO was cancelled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			provider.signingKey = provider.key
+			if tt.badSignature {
+				provider.signingKey = otherKey
+			}
+
+			_, _, body := ts.get(t, "/user/login/")
+			assert.StringContains(t, body, "<a href='/user/login/oidc/'>Log in with Example SSO</a>")
+
+			code, headers, _ := ts.get(t, "/user/login/oidc/")
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			authURL := headers.Get("Location")
+			if !strings.HasPrefix(authURL, provider.URL+"/authorize?") {
+				t.Fatalf("unexpected redirect to %q", authURL)
+			}
+
+			q := provider.authorize(t, authURL, tt.claims)
+			if tt.tamper != nil {
+				tt.tamper(q)
+			}
+
+			code, headers, _ = ts.get(t, "/user/login/oidc/callback/?"+q.Encode())
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantFlash != "" {
+				_, _, body := ts.get(t, tt.wantLocation)
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+
+			code, _, body = ts.get(t, "/account/view/")
+			if tt.wantEmail != "" {
+				assert.Equal(t, code, http.StatusOK)
+				assert.StringContains(t, body, tt.wantEmail)
+			} else {
+				assert.Equal(t, code, http.StatusSeeOther)
+			}
+		})
+	}
+}
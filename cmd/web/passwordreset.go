@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+// sendPasswordResetEmail creates a one-time reset token for the user and
+// emails them a link to choose a new password.
+func (app *application) sendPasswordResetEmail(user models.User) error {
+	token, err := app.passwordResets.New(user.ID, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"Name":      user.Name,
+		"URL":       fmt.Sprintf("%s/user/password/reset/%s/", app.baseURL, url.PathEscape(token)),
+		"ExpiresIn": "30 minutes",
+	}
+
+	return app.sendEmail(user.Email, "password-reset.tmpl", data)
+}
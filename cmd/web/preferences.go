@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// snippetExpiryDays are the lifetimes snippets can be created with.
+var snippetExpiryDays = []int{1, 7, 365}
+
+// preferencePageSizes are the listing page sizes users can choose from.
+var preferencePageSizes = []int{10, 25, 50}
+
+// preferenceChoices holds the options shown on the preferences page.
+type preferenceChoices struct {
+	Themes     []string
+	PageSizes  []int
+	ExpiryDays []int
+	Digests    []string
+}
+
+func newPreferenceChoices() preferenceChoices {
+	return preferenceChoices{
+		Themes:     models.Themes,
+		PageSizes:  preferencePageSizes,
+		ExpiryDays: snippetExpiryDays,
+		Digests:    models.Digests,
+	}
+}
+
+// userPreferences returns the preferences of the authenticated user, or the
+// defaults for anonymous visitors. A failure to load them is logged rather
+// than failing the request, since the defaults still work.
+func (app *application) userPreferences(r *http.Request) models.Preferences {
+	if !app.isAuthenticated(r) {
+		return models.DefaultPreferences()
+	}
+
+	prefs, err := app.preferences.Get(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.logger.Error("loading preferences", "error", err.Error(), "uri", r.URL.RequestURI())
+		return models.DefaultPreferences()
+	}
+
+	return prefs
+}
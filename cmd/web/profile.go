@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/markponce/snippetbox/internal/validator"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+	maxBioLength      = 500
+)
+
+// normalizeUsername lets users type their username in any case. Usernames
+// are stored in lowercase.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// checkUsername validates a username chosen at signup or on the profile page.
+func checkUsername(v *validator.Validator, username string) {
+	v.CheckField(validator.NotBlank(username), "username", "This field cannot be blank")
+	v.CheckField(validator.MinChars(username, minUsernameLength), "username", "This field must be at least 3 characters long")
+	v.CheckField(validator.MaxChars(username, maxUsernameLength), "username", "This field cannot be more than 30 characters long")
+	v.CheckField(validator.Matches(username, validator.UsernameRX), "username", "Use only lowercase letters, numbers, hyphens and underscores")
+	v.CheckField(validator.NotReserved(username, validator.ReservedUsernames...), "username", "This username is reserved")
+}
+
+// pageLinks holds the numbers of the pages before and after the current one.
+// Either is 0 if there's no such page.
+type pageLinks struct {
+	Prev int
+	Next int
+}
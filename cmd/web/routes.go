@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/justinas/alice"
+	"github.com/markponce/snippetbox/internal/models"
 	"github.com/markponce/snippetbox/ui"
 )
 
@@ -25,26 +26,114 @@ func (app *application) routes() http.Handler {
 
 	mux.HandleFunc("GET /ping", ping)
 
+	// Avatars don't need the session, so they're served without it.
+	mux.HandleFunc("GET /avatars/{id}/{version}/{size}", app.avatarImage)
+
 	// Unprotected application routes using the "dynamic" middleware chain.
 	// Use the nosurf middleware on all our 'dynamic' routes.
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
 	mux.Handle("GET /about/{$}", dynamic.ThenFunc(app.about))
-	mux.Handle("GET /snippet/view/{id}/{$}", dynamic.ThenFunc(app.snippetView))
 	mux.Handle("GET /user/signup/{$}", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup/{$}", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login/{$}", dynamic.ThenFunc(app.userLogin))
 	mux.Handle("POST /user/login/{$}", dynamic.ThenFunc(app.userLoginPost))
+	mux.Handle("GET /user/login/link/{$}", dynamic.ThenFunc(app.userLoginLink))
+	mux.Handle("POST /user/login/link/{$}", dynamic.ThenFunc(app.userLoginLinkPost))
+	mux.Handle("GET /user/login/link/{token}/{$}", dynamic.ThenFunc(app.userLoginLinkUse))
+	mux.Handle("GET /user/login/oidc/{$}", dynamic.ThenFunc(app.userLoginOIDC))
+	mux.Handle("GET /user/login/oidc/callback/{$}", dynamic.ThenFunc(app.userLoginOIDCCallback))
+	mux.Handle("GET /user/login/2fa/{$}", dynamic.ThenFunc(app.userLoginTwoFactor))
+	mux.Handle("POST /user/login/2fa/{$}", dynamic.ThenFunc(app.userLoginTwoFactorPost))
+	mux.Handle("GET /user/verify/{token}/{$}", dynamic.ThenFunc(app.userVerifyEmail))
+	mux.Handle("GET /user/email/confirm/{token}/{$}", dynamic.ThenFunc(app.userConfirmEmailChange))
+	mux.Handle("GET /snippet/renew/{token}/{$}", dynamic.ThenFunc(app.snippetRenew))
+	mux.Handle("POST /snippet/renew/{token}/{$}", dynamic.ThenFunc(app.snippetRenewPost))
+	mux.Handle("GET /user/password/forgot/{$}", dynamic.ThenFunc(app.userForgotPassword))
+	mux.Handle("POST /user/password/forgot/{$}", dynamic.ThenFunc(app.userForgotPasswordPost))
+	mux.Handle("GET /user/password/reset/{token}/{$}", dynamic.ThenFunc(app.userResetPassword))
+	mux.Handle("POST /user/password/reset/{token}/{$}", dynamic.ThenFunc(app.userResetPasswordPost))
+
+	// Routes which scripts can also use with a personal access token, as
+	// long as the token has the scope the route needs. Token requests skip
+	// the CSRF check; everything else is checked as usual.
+	token := func(scope string) alice.Chain {
+		return alice.New(app.sessionManager.LoadAndSave, app.authenticateToken(scope), noSurf, app.authenticate)
+	}
+	mux.Handle("GET /snippet/view/{id}/{$}", token(models.ScopeRead).ThenFunc(app.snippetView))
+	mux.Handle("GET /u/{username}/{$}", token(models.ScopeRead).ThenFunc(app.userProfile))
+	mux.Handle("GET /u/{username}/following/{$}", token(models.ScopeRead).ThenFunc(app.userFollowing))
+	mux.Handle("GET /u/{username}/followers/{$}", token(models.ScopeRead).ThenFunc(app.userFollowers))
+	mux.Handle("POST /snippet/create/{$}", token(models.ScopeWrite).Append(app.requireAuthetication, app.requireVerifiedEmail).ThenFunc(app.snippetCreatePost))
+	// Uploads are limited in size before noSurf reads the form.
+	mux.Handle("POST /account/import/{$}", alice.New(app.limitBody(maxImportSize)).Extend(token(models.ScopeWrite)).Append(app.requireAuthetication, app.requireVerifiedEmail).ThenFunc(app.accountImportPost))
+	mux.Handle("POST /snippet/delete/{id}/{$}", token(models.ScopeDelete).Append(app.requireAuthetication).ThenFunc(app.snippetDeletePost))
 
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
 	protected := dynamic.Append(app.requireAuthetication)
-	mux.Handle("GET /snippet/create/{$}", protected.ThenFunc(app.snippetCreate))
-	mux.Handle("POST /snippet/create/{$}", protected.ThenFunc(app.snippetCreatePost))
 	mux.Handle("POST /user/logout/{$}", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /following/{$}", protected.ThenFunc(app.homeFollowing))
+	mux.Handle("POST /u/{username}/follow/{$}", protected.ThenFunc(app.userFollowPost))
+	mux.Handle("POST /u/{username}/unfollow/{$}", protected.ThenFunc(app.userUnfollowPost))
 	mux.Handle("GET /account/view/{$}", protected.ThenFunc(app.accountView))
+	mux.Handle("POST /account/verify/resend/{$}", protected.ThenFunc(app.accountVerifyResendPost))
+	mux.Handle("GET /account/email/{$}", protected.ThenFunc(app.accountEmail))
+	mux.Handle("POST /account/email/{$}", protected.ThenFunc(app.accountEmailPost))
 	mux.Handle("GET /account/password/update/{$}", protected.ThenFunc(app.accountPasswordUpdate))
 	mux.Handle("POST /account/password/update/{$}", protected.ThenFunc(app.accountPasswordUpdatePost))
+	mux.Handle("POST /account/avatar/{$}", alice.New(app.limitBody(maxAvatarSize)).Extend(protected).ThenFunc(app.accountAvatarPost))
+	mux.Handle("POST /account/avatar/delete/{$}", protected.ThenFunc(app.accountAvatarDeletePost))
+	mux.Handle("GET /account/profile/{$}", protected.ThenFunc(app.accountProfile))
+	mux.Handle("POST /account/profile/{$}", protected.ThenFunc(app.accountProfilePost))
+	mux.Handle("GET /account/preferences/{$}", protected.ThenFunc(app.accountPreferences))
+	mux.Handle("POST /account/preferences/{$}", protected.ThenFunc(app.accountPreferencesPost))
+	mux.Handle("GET /account/notifications/{$}", protected.ThenFunc(app.accountNotifications))
+	mux.Handle("POST /account/notifications/{id}/read/{$}", protected.ThenFunc(app.accountNotificationReadPost))
+	mux.Handle("POST /account/notifications/read-all/{$}", protected.ThenFunc(app.accountNotificationsReadAllPost))
+	mux.Handle("GET /account/sessions/{$}", protected.ThenFunc(app.accountSessions))
+	mux.Handle("POST /account/sessions/{id}/revoke/{$}", protected.ThenFunc(app.accountSessionRevokePost))
+	mux.Handle("POST /account/sessions/revoke-others/{$}", protected.ThenFunc(app.accountSessionsRevokeOthersPost))
+	mux.Handle("GET /account/delete/{$}", protected.ThenFunc(app.accountDelete))
+	mux.Handle("POST /account/delete/{$}", protected.ThenFunc(app.accountDeletePost))
+	mux.Handle("GET /account/tokens/{$}", protected.ThenFunc(app.accountTokens))
+	mux.Handle("POST /account/tokens/{$}", protected.ThenFunc(app.accountTokensPost))
+	mux.Handle("POST /account/tokens/{id}/revoke/{$}", protected.ThenFunc(app.accountTokenRevokePost))
+	mux.Handle("GET /account/invites/{$}", protected.ThenFunc(app.accountInvites))
+	mux.Handle("POST /account/invites/{$}", protected.ThenFunc(app.accountInvitesPost))
+	mux.Handle("POST /account/invites/{id}/revoke/{$}", protected.ThenFunc(app.accountInviteRevokePost))
+	mux.Handle("GET /account/2fa/{$}", protected.ThenFunc(app.accountTwoFactor))
+	mux.Handle("GET /account/2fa/qr.png", protected.ThenFunc(app.accountTwoFactorQR))
+	mux.Handle("POST /account/2fa/enable/{$}", protected.ThenFunc(app.accountTwoFactorEnablePost))
+	mux.Handle("POST /account/2fa/disable/{$}", protected.ThenFunc(app.accountTwoFactorDisablePost))
+	mux.Handle("POST /snippet/report/{id}/{$}", protected.ThenFunc(app.snippetReportPost))
+
+	// Creating snippets also requires a verified email address.
+	verified := protected.Append(app.requireVerifiedEmail)
+	mux.Handle("GET /snippet/create/{$}", verified.ThenFunc(app.snippetCreate))
+	mux.Handle("GET /account/import/{$}", verified.ThenFunc(app.accountImport))
+
+	// Moderator-only routes.
+	moderator := protected.Append(app.requireModerator)
+	mux.Handle("GET /moderation/{$}", moderator.ThenFunc(app.moderationView))
+	mux.Handle("POST /moderation/report/{id}/{$}", moderator.ThenFunc(app.moderationResolvePost))
+
+	// Admin-only routes. Actions on users and snippets are a GET which shows
+	// a confirmation page, followed by a CSRF-protected POST which carries it
+	// out. Creating and revoking invites are plain CSRF-protected POSTs from
+	// the invites page, as they don't touch anyone's account or snippets.
+	admin := protected.Append(app.requireAdmin)
+	mux.Handle("GET /admin/{$}", admin.ThenFunc(app.adminDashboard))
+	mux.Handle("GET /admin/users/{$}", admin.ThenFunc(app.adminUsers))
+	mux.Handle("GET /admin/users/{id}/{action}/{$}", admin.ThenFunc(app.adminUserAction))
+	mux.Handle("POST /admin/users/{id}/{action}/{$}", admin.ThenFunc(app.adminUserActionPost))
+	mux.Handle("GET /admin/audit/{$}", admin.ThenFunc(app.adminAudit))
+	mux.Handle("GET /admin/invites/{$}", admin.ThenFunc(app.adminInvites))
+	mux.Handle("POST /admin/invites/{$}", admin.ThenFunc(app.adminInvitesPost))
+	mux.Handle("POST /admin/invites/{id}/revoke/{$}", admin.ThenFunc(app.adminInviteRevokePost))
+	mux.Handle("GET /admin/snippets/{$}", admin.ThenFunc(app.adminSnippets))
+	mux.Handle("GET /admin/snippets/{id}/{action}/{$}", admin.ThenFunc(app.adminSnippetAction))
+	mux.Handle("POST /admin/snippets/{id}/{action}/{$}", admin.ThenFunc(app.adminSnippetActionPost))
 
 	standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
 	return standard.Then(mux)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/markponce/snippetbox/internal/secrets"
+)
+
+// Server policies for snippets which look like they contain secrets.
+const (
+	// Don't scan snippets at all.
+	secretsModeOff = "off"
+	// Show a warning which the user must acknowledge before saving.
+	secretsModeWarn = "warn"
+	// Refuse to save the snippet.
+	secretsModeReject = "reject"
+)
+
+// scanForSecrets runs the content through the secret scanner. Any detections
+// are logged with the detector names and line numbers only -- the matched text
+// is never logged.
+func (app *application) scanForSecrets(r *http.Request, content string) []secrets.Finding {
+	if app.secretScanner == nil || app.secretsMode == secretsModeOff {
+		return nil
+	}
+
+	findings := app.secretScanner.Scan(content)
+	if len(findings) > 0 {
+		lines := make([]int, len(findings))
+		for i, f := range findings {
+			lines[i] = f.Line
+		}
+		app.logger.Warn("possible secret in snippet", "detectors", secretsDetectorNames(findings), "lines", lines, "uri", r.URL.RequestURI())
+	}
+
+	return findings
+}
+
+// secretsMessage returns the message shown to the user for a set of findings.
+func (app *application) secretsMessage(findings []secrets.Finding) string {
+	names := strings.Join(secretsDetectorNames(findings), ", ")
+
+	if app.secretsMode == secretsModeReject {
+		return fmt.Sprintf("This snippet looks like it contains secrets (%s) and cannot be saved", names)
+	}
+	return fmt.Sprintf("This snippet looks like it contains secrets (%s). Remove them, or confirm that you want to save it anyway", names)
+}
+
+func secretsDetectorNames(findings []secrets.Finding) []string {
+	var names []string
+	for _, f := range findings {
+		if !slices.Contains(names, f.Detector) {
+			names = append(names, f.Detector)
+		}
+	}
+	return names
+}
@@ -4,3 +4,31 @@ type sessionKey string
 
 const postLoginRedirectURLSessionKey = sessionKey("postLoginRedirectURL")
 const authenticatedUserIDSessionKey = sessionKey("authenticatedUserID")
+const verificationSentAtSessionKey = sessionKey("verificationSentAt")
+
+// Set between a correct password and a correct second factor for users with
+// two-factor authentication turned on.
+const pendingUserIDSessionKey = sessionKey("pendingUserID")
+const pendingSinceSessionKey = sessionKey("pendingSince")
+const twoFactorAttemptsSessionKey = sessionKey("twoFactorAttempts")
+
+// Holds the new TOTP secret while the user is setting up two-factor
+// authentication, until they confirm it with a first code.
+const totpEnrollSecretSessionKey = sessionKey("totpEnrollSecret")
+
+// Remember an OpenID Connect login while the user is at the identity
+// provider.
+const oidcStateSessionKey = sessionKey("oidcState")
+const oidcNonceSessionKey = sessionKey("oidcNonce")
+const oidcVerifierSessionKey = sessionKey("oidcVerifier")
+
+// Metadata about each logged-in session, shown on the account sessions page.
+// Times are stored as Unix timestamps.
+const sessionIDSessionKey = sessionKey("sessionID")
+const sessionCreatedSessionKey = sessionKey("sessionCreated")
+const sessionLastSeenSessionKey = sessionKey("sessionLastSeen")
+const sessionIPSessionKey = sessionKey("sessionIP")
+const sessionUserAgentSessionKey = sessionKey("sessionUserAgent")
+
+// Binds emailed login links to the browser which asked for them.
+const loginLinkNonceSessionKey = sessionKey("loginLinkNonce")
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/useragent"
+)
+
+// sessionTouchInterval limits how often the last seen time is updated, so
+// that not every request has to write the session back to the store.
+const sessionTouchInterval = time.Minute
+
+// sessionInfo describes one of a user's logged-in sessions.
+type sessionInfo struct {
+	// ID identifies the session on the sessions page. It's not the session
+	// token, which must never be shown.
+	ID       string
+	Device   string
+	IP       string
+	Created  time.Time
+	LastSeen time.Time
+	Current  bool
+}
+
+// recordSessionMetadata stores where and when the session was started. It's
+// called when a user logs in.
+func (app *application) recordSessionMetadata(r *http.Request) {
+	now := time.Now().Unix()
+
+	app.sessionManager.Put(r.Context(), string(sessionIDSessionKey), rand.Text())
+	app.sessionManager.Put(r.Context(), string(sessionCreatedSessionKey), now)
+	app.sessionManager.Put(r.Context(), string(sessionLastSeenSessionKey), now)
+	app.sessionManager.Put(r.Context(), string(sessionIPSessionKey), clientIP(r))
+	app.sessionManager.Put(r.Context(), string(sessionUserAgentSessionKey), r.UserAgent())
+}
+
+// touchSession updates the last seen time and address of an authenticated
+// session. Sessions from before metadata was recorded get it now.
+func (app *application) touchSession(r *http.Request) {
+	if app.sessionManager.GetString(r.Context(), string(sessionIDSessionKey)) == "" {
+		app.recordSessionMetadata(r)
+		return
+	}
+
+	lastSeen := app.sessionManager.GetInt64(r.Context(), string(sessionLastSeenSessionKey))
+	if time.Since(time.Unix(lastSeen, 0)) < sessionTouchInterval {
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), string(sessionLastSeenSessionKey), time.Now().Unix())
+	app.sessionManager.Put(r.Context(), string(sessionIPSessionKey), clientIP(r))
+}
+
+// userSessions returns every session the user is logged in to, most recently
+// used first.
+func (app *application) userSessions(ctx context.Context, userID int, currentID string) ([]sessionInfo, error) {
+	var sessions []sessionInfo
+
+	err := app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, string(authenticatedUserIDSessionKey)) != userID {
+			return nil
+		}
+
+		id := app.sessionManager.GetString(ctx, string(sessionIDSessionKey))
+
+		sessions = append(sessions, sessionInfo{
+			ID:       id,
+			Device:   useragent.Parse(app.sessionManager.GetString(ctx, string(sessionUserAgentSessionKey))).String(),
+			IP:       app.sessionManager.GetString(ctx, string(sessionIPSessionKey)),
+			Created:  unixTime(app.sessionManager.GetInt64(ctx, string(sessionCreatedSessionKey))),
+			LastSeen: unixTime(app.sessionManager.GetInt64(ctx, string(sessionLastSeenSessionKey))),
+			Current:  id != "" && id == currentID,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Current != sessions[j].Current {
+			return sessions[i].Current
+		}
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+// unixTime converts a stored timestamp, keeping zero as the zero time.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// destroyOtherSessions logs the user out of every session except the one
+// with currentID.
+func (app *application) destroyOtherSessions(ctx context.Context, userID int, currentID string) error {
+	return app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, string(authenticatedUserIDSessionKey)) != userID {
+			return nil
+		}
+		if app.sessionManager.GetString(ctx, string(sessionIDSessionKey)) == currentID {
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+}
+
+// destroySession logs the user out of the session with the given ID. It
+// returns false if the user has no such session.
+func (app *application) destroySession(ctx context.Context, userID int, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+
+	found := false
+
+	err := app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, string(authenticatedUserIDSessionKey)) != userID {
+			return nil
+		}
+		if app.sessionManager.GetString(ctx, string(sessionIDSessionKey)) != id {
+			return nil
+		}
+		found = true
+		return app.sessionManager.Destroy(ctx)
+	})
+
+	return found, err
+}
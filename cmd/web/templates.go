@@ -1,36 +1,81 @@
 package main
 
 import (
+	"fmt"
+	"html/template"
 	"io/fs"
 	"path/filepath"
-	"text/template"
 	"time"
 
+	"github.com/markponce/snippetbox/internal/langdetect"
 	"github.com/markponce/snippetbox/internal/models"
 	"github.com/markponce/snippetbox/ui"
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string // Add a CSRFToken field.
-	User            models.User
+	CurrentYear       int
+	Snippet           models.Snippet
+	Snippets          []models.Snippet
+	Form              any
+	Flash             string
+	IsAuthenticated   bool
+	IsModerator       bool
+	IsAdmin           bool
+	CSRFToken         string // Add a CSRFToken field.
+	User              models.User
+	ImportReport      importReport
+	Reports           []models.Report
+	ModerationLog     []models.ModerationEvent
+	ReportReasons     []string
+	Users             []models.User
+	AdminStats        adminStats
+	Confirm           adminConfirmation
+	Languages         []langdetect.Language
+	TwoFactor         twoFactorPage
+	SSOName           string
+	LockedUsers       map[int]bool
+	Sessions          []sessionInfo
+	DeleteAfter       time.Time
+	Author            models.User
+	Pages             pageLinks
+	AccessTokens      accessTokensPage
+	AuditEvents       []models.AuditEvent
+	Invites           invitesPage
+	Preferences       models.Preferences
+	Location          *time.Location
+	PreferenceChoices preferenceChoices
+	Notifications     []models.Notification
+	Follows           followPage
+	Cursor            cursorLinks
+	RenewToken        string
+	RenewDays         int
+	// Following is set on the home page's "Following" tab.
+	Following bool
+	// UnreadNotifications is shown next to the bell in the navigation bar.
+	UnreadNotifications int
 }
 
-func humanDate(t time.Time) string {
-
+// humanDate formats a time for people to read, in the given time zone.
+// Templates pass the Location from templateData, which follows the user's
+// preferences.
+func humanDate(t time.Time, loc *time.Location) string {
 	if t.IsZero() {
 		return ""
 	}
-	return t.UTC().Format("02 Jan 2006 at 15:04")
+	return t.In(loc).Format("02 Jan 2006 at 15:04")
+}
+
+// percent formats a fraction between 0 and 1 as a whole percentage.
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
 }
 
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"auditEvent":   auditEventName,
+	"avatarURL":    avatarURL,
+	"humanDate":    humanDate,
+	"languageName": langdetect.Name,
+	"percent":      percent,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
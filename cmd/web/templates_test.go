@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/markponce/snippetbox/internal/assert"
+	"github.com/markponce/snippetbox/internal/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -49,7 +52,7 @@ func TestHumanDate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hd := humanDate(tt.tm)
+			hd := humanDate(tt.tm, time.UTC)
 			// if hd != tt.want {
 			// 	t.Errorf("got %q, want %q", hd, tt.want)
 			// }
@@ -70,3 +73,106 @@ func TestHumanDate(t *testing.T) {
 	//     // And another...
 	// })
 }
+
+func TestHumanDateLocation(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		tm   time.Time
+		want string
+	}{
+		{
+			name: "UTC",
+			loc:  time.UTC,
+			tm:   time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			want: "17 Mar 2024 at 10:15",
+		},
+		{
+			name: "Winter time",
+			loc:  paris,
+			tm:   time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			want: "17 Mar 2024 at 11:15",
+		},
+		{
+			name: "Summer time",
+			loc:  paris,
+			tm:   time.Date(2024, 7, 17, 23, 15, 0, 0, time.UTC),
+			want: "18 Jul 2024 at 01:15",
+		},
+		{
+			name: "Empty",
+			loc:  paris,
+			tm:   time.Time{},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, humanDate(tt.tm, tt.loc), tt.want)
+		})
+	}
+}
+
+func TestTemplatesEscapeUserInput(t *testing.T) {
+	cache, err := newTemplateCache()
+	assert.NilError(t, err)
+
+	const script = "<script>alert(1)</script>"
+
+	tests := []struct {
+		name string
+		page string
+		data templateData
+	}{
+		{
+			name: "Report note",
+			page: "moderation.tmpl.html",
+			data: templateData{
+				Reports: []models.Report{{ID: 1, SnippetID: 1, SnippetTitle: script, Note: script}},
+			},
+		},
+		{
+			name: "Profile",
+			page: "profile.tmpl.html",
+			data: templateData{
+				User: models.User{ID: 1, Name: script, Username: script, Bio: script},
+			},
+		},
+		{
+			name: "Follow list",
+			page: "follows.tmpl.html",
+			data: templateData{
+				User:  models.User{ID: 1, Username: "alice"},
+				Users: []models.User{{ID: 2, Name: script, Username: script}},
+			},
+		},
+		{
+			name: "Notification",
+			page: "account-notifications.tmpl.html",
+			data: templateData{
+				Notifications: []models.Notification{{ID: 1, Message: script, Link: "/snippet/view/1/"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.data.Location = time.UTC
+
+			buf := new(bytes.Buffer)
+			err := cache[tt.page].ExecuteTemplate(buf, "base", tt.data)
+			assert.NilError(t, err)
+
+			assert.StringContains(t, buf.String(), "&lt;script&gt;alert(1)&lt;/script&gt;")
+			if strings.Contains(buf.String(), script) {
+				t.Errorf("%s is rendered unescaped", script)
+			}
+		})
+	}
+}
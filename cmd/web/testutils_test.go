@@ -5,17 +5,26 @@ import (
 	"html"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"github.com/markponce/snippetbox/internal/events"
+	"github.com/markponce/snippetbox/internal/mailer"
 	"github.com/markponce/snippetbox/internal/models/mocks"
+	"github.com/markponce/snippetbox/internal/secrets"
+	"github.com/markponce/snippetbox/internal/signer"
+	"github.com/markponce/snippetbox/internal/throttle"
+	"github.com/markponce/snippetbox/internal/validator"
 )
 
 // Create a newTestApplication helper which returns an instance of our
@@ -35,15 +44,66 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
-	return &application{
-		logger:         slog.New(slog.DiscardHandler),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+	app := &application{
+		logger:              slog.New(slog.DiscardHandler),
+		snippets:            &mocks.SnippetModel{},
+		users:               &mocks.UserModel{},
+		moderation:          &mocks.ModerationModel{},
+		stats:               &mocks.StatsModel{},
+		passwordResets:      &mocks.PasswordResetModel{},
+		twoFactor:           &mocks.TwoFactorModel{},
+		identities:          &mocks.IdentityModel{},
+		avatars:             &mocks.AvatarModel{},
+		accessTokens:        &mocks.AccessTokenModel{},
+		auditEvents:         &mocks.AuditModel{},
+		invites:             &mocks.InviteModel{},
+		loginLinks:          &mocks.LoginLinkModel{},
+		preferences:         &mocks.PreferenceModel{},
+		notifications:       &mocks.NotificationModel{},
+		follows:             &mocks.FollowModel{},
+		digests:             &mocks.DigestModel{},
+		templateCache:       templateCache,
+		formDecoder:         formDecoder,
+		sessionManager:      sessionManager,
+		secretScanner:       secrets.NewScanner(secrets.DefaultDetectors()...),
+		passwordPolicy:      validator.NewPasswordPolicy(validator.CommonPasswords),
+		secretsMode:         secretsModeWarn,
+		signupMode:          signupModeOpen,
+		mailer:              &testMailer{},
+		signer:              signer.New([]byte("test-key")),
+		loginThrottle:       throttle.New(accountThrottlePolicy),
+		ipThrottle:          throttle.New(ipThrottlePolicy),
+		events:              events.NewDispatcher(),
+		deletionGracePeriod: 14 * 24 * time.Hour,
+		baseURL:             "https://snippetbox.test",
 	}
+	app.subscribeNotifications()
 
+	return app
+}
+
+// testMailer records the emails sent by the application instead of sending
+// them.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// last returns the most recently sent email.
+func (m *testMailer) last(t *testing.T) mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	return m.sent[len(m.sent)-1]
 }
 
 // Define a custom testServer type which embeds a httptest.Server instance.
@@ -138,3 +198,95 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// login signs in as one of the users from the mock user model, so that
+// subsequent requests made with the same test server client are
+// authenticated.
+func (ts *testServer) login(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login/")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login/", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
+
+// postMultipart sends a multipart/form-data POST request with the given form
+// values and a single file stored under fileField.
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, form url.Values, fileField, fileName string, fileData []byte) (int, http.Header, string) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	for key, values := range form {
+		for _, value := range values {
+			err := mw.WriteField(key, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if fileField != "" {
+		fw, err := mw.CreateFormFile(fileField, fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fw.Write(fileData)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// bearer sends a request authenticated with a personal access token. The
+// form, if any, is sent as the request body. Cookies from the test server
+// client's jar are sent as usual.
+func (ts *testServer) bearer(t *testing.T, method, urlPath, token string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/throttle"
+)
+
+// accountThrottlePolicy slows down password guessing against a single
+// account, whether or not the account exists, and locks it for a while
+// after too many failures.
+var accountThrottlePolicy = throttle.Policy{
+	Window:           15 * time.Minute,
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+}
+
+// ipThrottlePolicy slows down a single client trying many accounts. It
+// never locks anyone out, as several users can share an address.
+var ipThrottlePolicy = throttle.Policy{
+	Window:       15 * time.Minute,
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Minute,
+}
+
+// loginThrottleKey normalizes an email address so that changing its case
+// doesn't get around the limit.
+func loginThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns the address of the client, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginAllowed reports whether a login attempt for the email address may be
+// checked now.
+func (app *application) loginAllowed(r *http.Request, email string) bool {
+	key := loginThrottleKey(email)
+
+	account := app.loginThrottle.Check(key)
+	if account.Unlocked {
+		app.logger.Info("account lockout expired", "email", key)
+	}
+
+	return account.Allowed() && app.ipThrottle.Check(clientIP(r)).Allowed()
+}
+
+// loginFailed records a wrong password for the email address.
+func (app *application) loginFailed(r *http.Request, email string) {
+	key := loginThrottleKey(email)
+	ip := clientIP(r)
+
+	if app.loginThrottle.Fail(key) {
+		app.logger.Warn("account locked after failed logins", "email", key, "ip", ip, "until", app.loginThrottle.LockedUntil(key))
+	}
+
+	app.ipThrottle.Fail(ip)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+	"github.com/markponce/snippetbox/internal/totp"
+)
+
+const (
+	// totpIssuer is shown next to the account name in authenticator apps.
+	totpIssuer = "Snippetbox"
+	// pendingLoginTTL is how long a user has to enter their second factor
+	// after entering the right password.
+	pendingLoginTTL = 5 * time.Minute
+	// maxTwoFactorAttempts is how many wrong codes are allowed before the
+	// user has to enter their password again.
+	maxTwoFactorAttempts = 5
+)
+
+// twoFactorPage holds the data for the two-factor settings page.
+type twoFactorPage struct {
+	Secret        string
+	RecoveryCodes []string
+	CodesLeft     int
+}
+
+// checkSecondFactor reports whether code is a valid TOTP code or an unused
+// recovery code for the user. Either kind of code only works once.
+func (app *application) checkSecondFactor(userID int, code string) (bool, error) {
+	secret, err := app.twoFactor.Secret(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if step, ok := totp.Validate(secret, code, time.Now()); ok {
+		err = app.twoFactor.UseStep(userID, step)
+	} else {
+		err = app.twoFactor.UseRecoveryCode(userID, code)
+	}
+
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
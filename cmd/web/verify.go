@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+const (
+	verifyEmailPurpose = "verify-email"
+	verifyEmailTTL     = 24 * time.Hour
+)
+
+// sendVerificationEmail emails the user a signed link which proves they own
+// their email address. The address is part of the signed payload, so the
+// link stops working if the address changes.
+func (app *application) sendVerificationEmail(user models.User) error {
+	payload := strconv.Itoa(user.ID) + ":" + user.Email
+	token := app.signer.Sign(verifyEmailPurpose, payload, time.Now().Add(verifyEmailTTL))
+
+	data := map[string]any{
+		"Name":      user.Name,
+		"URL":       fmt.Sprintf("%s/user/verify/%s/", app.baseURL, url.PathEscape(token)),
+		"ExpiresIn": "24 hours",
+	}
+
+	return app.sendEmail(user.Email, "verify-email.tmpl", data)
+}
+
+// parseVerificationToken checks a token from a verification link and
+// returns the user ID and email address it was issued for.
+func (app *application) parseVerificationToken(token string) (int, string, error) {
+	payload, err := app.signer.Verify(verifyEmailPurpose, token, time.Now())
+	if err != nil {
+		return 0, "", err
+	}
+
+	idStr, email, ok := strings.Cut(payload, ":")
+	if !ok {
+		return 0, "", fmt.Errorf("malformed verification payload")
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return id, email, nil
+}
@@ -0,0 +1,143 @@
+// Package avatar turns uploaded pictures into square PNG thumbnails and
+// generates identicons for users who haven't uploaded one.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	// Register the formats image.Decode understands.
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// Sizes are the widths (and heights) of the thumbnails made from every
+// upload, in pixels.
+var Sizes = []int{160, 40}
+
+// MaxDimension is the largest width or height accepted. Checking it before
+// decoding stops small files which decompress into huge images.
+const MaxDimension = 4096
+
+var (
+	ErrFormat     = errors.New("avatar: the file must be a PNG, JPEG or GIF image")
+	ErrDimensions = errors.New("avatar: the image is too large")
+)
+
+// ValidSize reports whether thumbnails are made in the given size.
+func ValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode reads a PNG, JPEG or GIF image. The format is detected from the
+// content rather than trusted from the upload. Only the first frame of an
+// animated GIF is used.
+func Decode(data []byte) (image.Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return nil, ErrFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, ErrDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	return img, nil
+}
+
+// Thumbnails decodes an uploaded image and returns a PNG thumbnail for each
+// of Sizes, keyed by size.
+func Thumbnails(data []byte) (map[int][]byte, error) {
+	img, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbs := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		thumbs[size], err = encodePNG(Resize(img, size))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return thumbs, nil
+}
+
+// Resize crops the middle square out of img and scales it to size×size
+// pixels. Each output pixel is the average of the input pixels it covers, so
+// large photos don't come out grainy.
+func Resize(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+
+	// Copying into an NRGBA image first lets the loop below read the pixels
+	// directly, whatever the input format was.
+	src := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	if side == 0 {
+		return dst
+	}
+
+	for y := 0; y < size; y++ {
+		y0, y1 := y*side/size, max((y+1)*side/size, y*side/size+1)
+
+		for x := 0; x < size; x++ {
+			x0, x1 := x*side/size, max((x+1)*side/size, x*side/size+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					for i := range sum {
+						sum[i] += int(p[i])
+					}
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			p := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			for i := range sum {
+				p[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	err := png.Encode(buf, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
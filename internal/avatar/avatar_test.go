@@ -0,0 +1,138 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+// testImage returns a width×height image whose left half is red and right
+// half is blue.
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{0xff, 0, 0, 0xff}
+			if x >= width/2 {
+				c = color.NRGBA{0, 0, 0xff, 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, format string, img image.Image) []byte {
+	buf := new(bytes.Buffer)
+
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "jpeg":
+		err = jpeg.Encode(buf, img, nil)
+	case "gif":
+		err = gif.Encode(buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestThumbnails(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name: "PNG",
+			data: encode(t, "png", testImage(300, 200)),
+		},
+		{
+			name: "JPEG",
+			data: encode(t, "jpeg", testImage(200, 300)),
+		},
+		{
+			name: "GIF",
+			data: encode(t, "gif", testImage(20, 20)),
+		},
+		{
+			name:    "Not an image",
+			data:    []byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"),
+			wantErr: ErrFormat,
+		},
+		{
+			name:    "Truncated PNG",
+			data:    encode(t, "png", testImage(50, 50))[:60],
+			wantErr: ErrFormat,
+		},
+		{
+			name:    "Too large",
+			data:    encode(t, "png", image.NewGray(image.Rect(0, 0, MaxDimension+1, 1))),
+			wantErr: ErrDimensions,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbs, err := Thumbnails(tt.data)
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.Equal(t, len(thumbs), len(Sizes))
+			for _, size := range Sizes {
+				img, err := png.Decode(bytes.NewReader(thumbs[size]))
+				assert.NilError(t, err)
+				assert.Equal(t, img.Bounds(), image.Rect(0, 0, size, size))
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	// The middle square of a wide image is kept, so both colours survive.
+	img := Resize(testImage(400, 100), 10)
+
+	assert.Equal(t, img.Bounds(), image.Rect(0, 0, 10, 10))
+	assert.Equal(t, img.NRGBAAt(0, 5), color.NRGBA{0xff, 0, 0, 0xff})
+	assert.Equal(t, img.NRGBAAt(9, 5), color.NRGBA{0, 0, 0xff, 0xff})
+
+	// Small images are scaled up.
+	img = Resize(testImage(4, 4), 40)
+	assert.Equal(t, img.Bounds(), image.Rect(0, 0, 40, 40))
+	assert.Equal(t, img.NRGBAAt(39, 39), color.NRGBA{0, 0, 0xff, 0xff})
+}
+
+func TestIdenticon(t *testing.T) {
+	a, err := Identicon("1", 40)
+	assert.NilError(t, err)
+
+	again, err := Identicon("1", 40)
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(a, again), true)
+
+	b, err := Identicon("2", 40)
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(a, b), false)
+
+	img := identicon("1", 40)
+	assert.Equal(t, img.Bounds(), image.Rect(0, 0, 40, 40))
+
+	// The pattern is mirrored left to right.
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 20; x++ {
+			assert.Equal(t, img.NRGBAAt(x, y), img.NRGBAAt(39-x, y))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package avatar
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+)
+
+// identiconCells is the number of cells along each side of an identicon.
+const identiconCells = 5
+
+var identiconBackground = color.NRGBA{0xf0, 0xf0, 0xf0, 0xff}
+
+// Identicon returns a size×size PNG made from a hash of seed. The same seed
+// always gives the same picture. The pattern is mirrored left to right, like
+// a face.
+func Identicon(seed string, size int) ([]byte, error) {
+	return encodePNG(identicon(seed, size))
+}
+
+func identicon(seed string, size int) *image.NRGBA {
+	hash := sha256.Sum256([]byte(seed))
+
+	// The first three bytes pick the colour. Keep it dark enough to stand out
+	// against the background.
+	fg := color.NRGBA{hash[0] / 2, hash[1] / 2, hash[2] / 2, 0xff}
+
+	// Only the left half (including the middle column) is taken from the
+	// hash; the right half mirrors it.
+	var filled [identiconCells][identiconCells]bool
+	for row := 0; row < identiconCells; row++ {
+		for col := 0; col < (identiconCells+1)/2; col++ {
+			on := hash[3+row*identiconCells+col]%2 == 0
+			filled[row][col] = on
+			filled[row][identiconCells-1-col] = on
+		}
+	}
+
+	// Leave a margin of about half a cell around the pattern.
+	cell := size / (identiconCells + 1)
+	inner := cell * identiconCells
+	margin := (size - inner) / 2
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := identiconBackground
+
+			if x >= margin && y >= margin && x < margin+inner && y < margin+inner {
+				if filled[(y-margin)/cell][(x-margin)/cell] {
+					c = fg
+				}
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,58 @@
+// Package events lets one part of the application announce that something
+// happened without knowing who reacts to it. Handlers subscribe to events by
+// name and are called synchronously, in the order they subscribed.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Event is something which happened. EventName identifies the kind of event
+// handlers subscribe to.
+type Event interface {
+	EventName() string
+}
+
+// Handler reacts to an event. It can type-assert the event to the concrete
+// type published under the name it subscribed to.
+type Handler func(ctx context.Context, e Event) error
+
+// Dispatcher passes published events to the handlers subscribed to them. It
+// is safe for concurrent use.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe calls h for every event published with the given name.
+func (d *Dispatcher) Subscribe(name string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[name] = append(d.handlers[name], h)
+}
+
+// Publish calls the handlers subscribed to the event. A failing handler
+// doesn't stop the others; their errors are joined and returned.
+func (d *Dispatcher) Publish(ctx context.Context, e Event) error {
+	d.mu.RLock()
+	handlers := d.handlers[e.EventName()]
+	d.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		err := h(ctx, e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("events: %s: %w", e.EventName(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+type testEvent struct {
+	name string
+}
+
+func (e testEvent) EventName() string {
+	return e.name
+}
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher()
+
+	var calls []string
+	d.Subscribe("created", func(ctx context.Context, e Event) error {
+		calls = append(calls, "first")
+		return nil
+	})
+	d.Subscribe("created", func(ctx context.Context, e Event) error {
+		calls = append(calls, "second")
+		return nil
+	})
+	d.Subscribe("deleted", func(ctx context.Context, e Event) error {
+		calls = append(calls, "deleted")
+		return nil
+	})
+
+	t.Run("Subscribers in order", func(t *testing.T) {
+		calls = nil
+		err := d.Publish(context.Background(), testEvent{"created"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(calls), 2)
+		assert.Equal(t, calls[0], "first")
+		assert.Equal(t, calls[1], "second")
+	})
+
+	t.Run("No subscribers", func(t *testing.T) {
+		calls = nil
+		err := d.Publish(context.Background(), testEvent{"updated"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(calls), 0)
+	})
+
+	t.Run("Failing handler", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		d.Subscribe("deleted", func(ctx context.Context, e Event) error {
+			return errBoom
+		})
+		d.Subscribe("deleted", func(ctx context.Context, e Event) error {
+			calls = append(calls, "after")
+			return nil
+		})
+
+		calls = nil
+		err := d.Publish(context.Background(), testEvent{"deleted"})
+		assert.Equal(t, errors.Is(err, errBoom), true)
+		assert.Equal(t, len(calls), 2)
+		assert.Equal(t, calls[1], "after")
+	})
+}
@@ -0,0 +1,225 @@
+package langdetect
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Language is a programming language a snippet can be tagged with.
+type Language struct {
+	ID   string
+	Name string
+}
+
+// Languages lists every supported language, sorted by name.
+var Languages = []Language{
+	{"c", "C"},
+	{"css", "CSS"},
+	{"go", "Go"},
+	{"html", "HTML"},
+	{"java", "Java"},
+	{"javascript", "JavaScript"},
+	{"perl", "Perl"},
+	{"php", "PHP"},
+	{"python", "Python"},
+	{"ruby", "Ruby"},
+	{"rust", "Rust"},
+	{"shell", "Shell"},
+	{"sql", "SQL"},
+}
+
+// IDs returns the ID of every supported language.
+func IDs() []string {
+	ids := make([]string, len(Languages))
+	for i, l := range Languages {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+// Name returns the display name for a language ID, or the ID itself if it
+// isn't known.
+func Name(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Name
+		}
+	}
+	return id
+}
+
+// Guess is the result of detecting the language of some content. Confidence
+// is between 0 and 1. The zero Guess means no language could be detected.
+type Guess struct {
+	Language   string
+	Confidence float64
+}
+
+// Detect infers the language of content. Shebang lines are checked first,
+// then well-known file signatures, and finally a token-frequency classifier.
+func Detect(content string) Guess {
+	if g, ok := detectShebang(content); ok {
+		return g
+	}
+
+	if g, ok := detectSignature(content); ok {
+		return g
+	}
+
+	return classify(content)
+}
+
+// FromFilename guesses the language from a file extension, e.g. when
+// importing files from an archive.
+func FromFilename(name string) Guess {
+	if id, ok := extensions[strings.ToLower(path.Ext(name))]; ok {
+		return Guess{Language: id, Confidence: 1}
+	}
+	return Guess{}
+}
+
+var extensions = map[string]string{
+	".c":    "c",
+	".h":    "c",
+	".css":  "css",
+	".go":   "go",
+	".html": "html",
+	".htm":  "html",
+	".java": "java",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".pl":   "perl",
+	".php":  "php",
+	".py":   "python",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".sh":   "shell",
+	".bash": "shell",
+	".sql":  "sql",
+}
+
+var interpreters = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"dash":    "shell",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"ruby":    "ruby",
+	"php":     "php",
+	"perl":    "perl",
+}
+
+// detectShebang looks at a "#!/bin/bash" or "#!/usr/bin/env python3" line.
+func detectShebang(content string) (Guess, bool) {
+	line, _, _ := strings.Cut(strings.TrimLeft(content, " \t\r\n"), "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return Guess{}, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return Guess{}, false
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+
+	if id, ok := interpreters[interpreter]; ok {
+		return Guess{Language: id, Confidence: 1}, true
+	}
+
+	return Guess{}, false
+}
+
+var signatures = []struct {
+	rx       *regexp.Regexp
+	language string
+}{
+	{regexp.MustCompile(`^\s*<\?php`), "php"},
+	{regexp.MustCompile(`(?m)^package [a-z_][a-z0-9_]*\s*$`), "go"},
+	{regexp.MustCompile(`(?i)^\s*<!DOCTYPE html`), "html"},
+	{regexp.MustCompile(`(?m)^#include\s*[<"]`), "c"},
+	{regexp.MustCompile(`(?m)^\s*fn main\(\)`), "rust"},
+	{regexp.MustCompile(`public static void main\s*\(`), "java"},
+}
+
+// detectSignature matches content that only one language would start with.
+func detectSignature(content string) (Guess, bool) {
+	for _, s := range signatures {
+		if s.rx.MatchString(content) {
+			return Guess{Language: s.language, Confidence: 0.95}, true
+		}
+	}
+	return Guess{}, false
+}
+
+// keywords holds tokens which are characteristic of each language, with a
+// weight for how strongly they point to it.
+var keywords = map[string]map[string]float64{
+	"c":          {"#include": 3, "printf": 2, "malloc": 3, "sizeof": 2, "struct": 1, "int": 1, "void": 1, "char": 1, "->": 1, "NULL": 2},
+	"css":        {"{": 0.5, "}": 0.5, "color": 2, "margin": 2, "padding": 2, "display": 2, "px": 1, "font-size": 3, "background": 2, "@media": 3},
+	"go":         {"func": 3, ":=": 3, "package": 2, "import": 1, "err": 2, "nil": 2, "chan": 3, "defer": 3, "go": 1, "struct": 1, "fmt": 2},
+	"html":       {"<div": 3, "</div>": 3, "<a": 2, "href": 2, "<p>": 2, "<span": 3, "class": 1, "<body>": 3, "<head>": 3, "<script": 2},
+	"java":       {"public": 2, "private": 2, "class": 1, "static": 1, "void": 1, "new": 1, "String": 2, "System.out.println": 4, "extends": 2, "implements": 3},
+	"javascript": {"function": 2, "const": 2, "let": 2, "var": 1, "=>": 2, "console.log": 4, "document": 3, "===": 3, "undefined": 3, "require": 2},
+	"perl":       {"my": 2, "sub": 2, "use": 1, "strict": 2, "foreach": 1, "$_": 3, "print": 1, "=~": 3},
+	"php":        {"<?php": 5, "echo": 2, "function": 1, "$this": 3, "->": 1, "array": 2, "=>": 1, "public": 1, "namespace": 1},
+	"python":     {"def": 3, "import": 1, "from": 1, "self": 3, "elif": 4, "None": 3, "True": 2, "False": 2, "print": 1, "lambda": 2, "__init__": 4},
+	"ruby":       {"def": 2, "end": 3, "puts": 3, "require": 1, "do": 1, "elsif": 4, "nil": 2, "attr_accessor": 4, "module": 1},
+	"rust":       {"fn": 3, "let": 1, "mut": 4, "impl": 3, "pub": 2, "match": 1, "println!": 4, "::": 1, "use": 1, "Some": 2, "Ok": 1},
+	"shell":      {"echo": 2, "fi": 4, "then": 2, "esac": 4, "done": 2, "export": 2, "$1": 3, "grep": 2, "sudo": 3, "&&": 1},
+	"sql":        {"SELECT": 3, "FROM": 2, "WHERE": 2, "INSERT": 3, "INTO": 2, "UPDATE": 2, "CREATE": 2, "TABLE": 2, "JOIN": 3, "VALUES": 2},
+}
+
+var tokenRX = regexp.MustCompile(`[<#@$]?[A-Za-z_][A-Za-z0-9_.!]*>?|:=|=>|->|===|=~|::|&&|\$[0-9_]|[{}]|<\?php|</?[a-z]+>?`)
+
+// minScore is the lowest total score which is considered a real match, so a
+// couple of common words don't produce a guess.
+const minScore = 6
+
+// classify scores every language by the weighted frequency of its keywords.
+// Confidence is the winning language's share of the total score.
+func classify(content string) Guess {
+	scores := make(map[string]float64)
+
+	for _, token := range tokenRX.FindAllString(content, -1) {
+		for language, words := range keywords {
+			if w, ok := words[token]; ok {
+				scores[language] += w
+			}
+		}
+	}
+
+	var total float64
+	ranked := make([]string, 0, len(scores))
+	for language, score := range scores {
+		total += score
+		ranked = append(ranked, language)
+	}
+
+	if len(ranked) == 0 {
+		return Guess{}
+	}
+
+	// Sort by score, then by name so ties are broken deterministically.
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] != scores[ranked[j]] {
+			return scores[ranked[i]] > scores[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	best := ranked[0]
+	if scores[best] < minScore {
+		return Guess{}
+	}
+
+	return Guess{Language: best, Confidence: scores[best] / total}
+}
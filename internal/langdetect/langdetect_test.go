@@ -0,0 +1,72 @@
+package langdetect
+
+import (
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Bash shebang",
+			content: "#!/bin/bash\necho hello",
+			want:    "shell",
+		},
+		{
+			name:    "Env shebang",
+			content: "#!/usr/bin/env python3\nprint('hello')",
+			want:    "python",
+		},
+		{
+			name:    "Go package",
+			content: "package main\n\nfunc main() {}",
+			want:    "go",
+		},
+		{
+			name:    "PHP",
+			content: "<?php\necho 'hello';",
+			want:    "php",
+		},
+		{
+			name:    "Python classifier",
+			content: "class Pond:\n    def __init__(self):\n        self.frog = None\n\n    def jump(self):\n        if self.frog:\n            return True\n        elif self.frog is None:\n            return False",
+			want:    "python",
+		},
+		{
+			name:    "SQL classifier",
+			content: "SELECT id, title FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC",
+			want:    "sql",
+		},
+		{
+			name:    "JavaScript classifier",
+			content: "const links = document.querySelectorAll('a');\nlinks.forEach((l) => console.log(l));",
+			want:    "javascript",
+		},
+		{
+			name:    "Prose",
+			content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Detect(tt.content)
+			assert.Equal(t, g.Language, tt.want)
+
+			if tt.want != "" && (g.Confidence <= 0 || g.Confidence > 1) {
+				t.Errorf("got confidence %v; want a value in (0, 1]", g.Confidence)
+			}
+		})
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	assert.Equal(t, FromFilename("haiku/pond.PY").Language, "python")
+	assert.Equal(t, FromFilename("README").Language, "")
+}
@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a single email. HTML is optional; when it's set the email is
+// sent as multipart/alternative with both bodies.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer is implemented by anything that can deliver an email. The SMTP
+// mailer is used in production, the file and log mailers in development and
+// tests.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer delivers email through an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	body, err := buildMessage(m.Sender, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, senderAddress(m.Sender), []string{msg.To}, body)
+}
+
+// FileMailer writes every email as an .eml file into Dir, so messages can be
+// opened in a mail client during development.
+type FileMailer struct {
+	Dir    string
+	Sender string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	body, err := buildMessage(m.Sender, msg)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), randomHex(4))
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o644)
+}
+
+// LogMailer writes every email to a logger instead of sending it.
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.Logger.Info("email", "to", msg.To, "subject", msg.Subject, "body", msg.Text)
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 message.
+func buildMessage(sender string, msg Message) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "From: %s\r\n", sender)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		fmt.Fprintf(buf, "Content-Type: text/plain; charset=UTF-8\r\n")
+		fmt.Fprintf(buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(buf, msg.Text)
+		return buf.Bytes(), err
+	}
+
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	}
+
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeQuotedPrintable(pw, p.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := mw.Close()
+	return buf.Bytes(), err
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	_, err := qw.Write([]byte(s))
+	if err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// senderAddress extracts the bare address from a sender such as
+// "Snippetbox <no-reply@example.com>".
+func senderAddress(sender string) string {
+	if i := strings.LastIndex(sender, "<"); i >= 0 {
+		return strings.TrimSuffix(sender[i+1:], ">")
+	}
+	return sender
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
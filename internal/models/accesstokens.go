@@ -0,0 +1,162 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes limit what a personal access token can be used for.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete}
+
+// accessTokenPrefix makes tokens easy to recognise, e.g. when they've been
+// pasted somewhere they shouldn't be.
+const accessTokenPrefix = "sbx_"
+
+type AccessToken struct {
+	ID     int
+	UserID int
+	Name   string
+	Scopes []string
+	// Expires and LastUsed are the zero time if the token never expires or
+	// hasn't been used yet.
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+// HasScope reports whether the token can be used for the given scope.
+func (t AccessToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type AccessTokenModelInterface interface {
+	New(userID int, name string, scopes []string, expires time.Time) (string, error)
+	Authenticate(token string) (AccessToken, error)
+	List(userID int) ([]AccessToken, error)
+	Revoke(userID, id int) error
+}
+
+// AccessTokenModel stores personal access tokens, which let scripts act as a
+// user without a browser session. Only a SHA-256 hash of each token is
+// stored.
+type AccessTokenModel struct {
+	DB *sql.DB
+}
+
+// New creates a token and returns it in plaintext. This is the only time the
+// plaintext is available. A zero expires means the token never expires.
+func (m *AccessTokenModel) New(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	token := accessTokenPrefix + rand.Text()
+
+	var expiresAt sql.NullTime
+	if !expires.IsZero() {
+		expiresAt = sql.NullTime{Time: expires.UTC(), Valid: true}
+	}
+
+	stmt := `INSERT INTO access_tokens (user_id, name, token_hash, scopes, created, expires)
+    VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err := m.DB.Exec(stmt, userID, name, hashToken(token), strings.Join(scopes, ","), expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate returns the token, or ErrNoRecord if it doesn't exist or has
+// expired. It also records that the token has been used.
+func (m *AccessTokenModel) Authenticate(token string) (AccessToken, error) {
+	stmt := `SELECT id, user_id, name, scopes, created, expires, last_used FROM access_tokens
+    WHERE token_hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	t, err := scanAccessToken(m.DB.QueryRow(stmt, hashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AccessToken{}, ErrNoRecord
+		}
+		return AccessToken{}, err
+	}
+
+	// Scripts can make a lot of requests, so the last used time is only
+	// updated once a minute.
+	stmt = `UPDATE access_tokens SET last_used = UTC_TIMESTAMP()
+    WHERE id = ? AND (last_used IS NULL OR last_used < DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE))`
+
+	_, err = m.DB.Exec(stmt, t.ID)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	return t, nil
+}
+
+// List returns the user's tokens, newest first, including expired ones.
+func (m *AccessTokenModel) List(userID int) ([]AccessToken, error) {
+	stmt := `SELECT id, user_id, name, scopes, created, expires, last_used FROM access_tokens
+    WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tokens []AccessToken
+
+	for rows.Next() {
+		t, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke deletes one of the user's tokens, or returns ErrNoRecord if they
+// don't have a token with that ID.
+func (m *AccessTokenModel) Revoke(userID, id int) error {
+	result, err := m.DB.Exec("DELETE FROM access_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+func scanAccessToken(row rowScanner) (AccessToken, error) {
+	var t AccessToken
+	var scopes string
+	var expires, lastUsed sql.NullTime
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &expires, &lastUsed)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.Expires = expires.Time
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
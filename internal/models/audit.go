@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Account events recorded in the audit log.
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login-failed"
+	AuditLogout         = "logout"
+	AuditPasswordChange = "password-change"
+	AuditPasswordReset  = "password-reset"
+	AuditEmailChange    = "email-change"
+	AuditTokenCreate    = "token-create"
+	AuditTokenRevoke    = "token-revoke"
+	AuditSessionRevoke  = "session-revoke"
+)
+
+// AuditEvent is a single entry in the security audit log.
+type AuditEvent struct {
+	ID int
+	// UserID is 0 if the event couldn't be tied to an account, like a failed
+	// login for an unknown email address.
+	UserID    int
+	Event     string
+	Detail    string
+	IP        string
+	UserAgent string
+	Created   time.Time
+	// User is the name of the user, or empty if there's no such user.
+	User string
+}
+
+type AuditModelInterface interface {
+	Record(event AuditEvent) error
+	ForUser(userID, limit int) ([]AuditEvent, error)
+	Search(query string, limit int) ([]AuditEvent, error)
+}
+
+// AuditModel stores the security audit log. The log is append-only: there
+// are no methods to change or remove events, and the table's triggers
+// refuse to. The one exception is deleting an account, which clears the
+// detail, IP address and User-Agent of the account's events.
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Record appends an event to the log.
+func (m *AuditModel) Record(event AuditEvent) error {
+	var userID sql.NullInt64
+	if event.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(event.UserID), Valid: true}
+	}
+
+	stmt := `INSERT INTO audit_events (user_id, event, detail, ip, user_agent, created)
+    VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, event.Event, event.Detail, event.IP, event.UserAgent)
+	return err
+}
+
+// ForUser returns the user's most recent events, newest first.
+func (m *AuditModel) ForUser(userID, limit int) ([]AuditEvent, error) {
+	stmt := `SELECT a.id, a.user_id, a.event, a.detail, a.ip, a.user_agent, a.created, COALESCE(u.name, '')
+    FROM audit_events a LEFT JOIN users u ON u.id = a.user_id
+    WHERE a.user_id = ? ORDER BY a.id DESC LIMIT ?`
+
+	return m.query(stmt, userID, limit)
+}
+
+// Search returns the most recent events, newest first, whose user's name or
+// email, detail or IP address contains the query, or whose event is exactly
+// the query. An empty query matches every event.
+func (m *AuditModel) Search(query string, limit int) ([]AuditEvent, error) {
+	stmt := `SELECT a.id, a.user_id, a.event, a.detail, a.ip, a.user_agent, a.created, COALESCE(u.name, '')
+    FROM audit_events a LEFT JOIN users u ON u.id = a.user_id
+    WHERE a.event = ? OR u.name LIKE ? OR u.email LIKE ? OR a.detail LIKE ? OR a.ip LIKE ?
+    ORDER BY a.id DESC LIMIT ?`
+
+	pattern := "%" + escapeLike(query) + "%"
+
+	return m.query(stmt, query, pattern, pattern, pattern, pattern, limit)
+}
+
+func (m *AuditModel) query(stmt string, args ...any) ([]AuditEvent, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+		var userID sql.NullInt64
+
+		err = rows.Scan(&e.ID, &userID, &e.Event, &e.Detail, &e.IP, &e.UserAgent, &e.Created, &e.User)
+		if err != nil {
+			return nil, err
+		}
+
+		e.UserID = int(userID.Int64)
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type AvatarModelInterface interface {
+	Get(userID, size int) ([]byte, error)
+	Set(userID int, thumbnails map[int][]byte) error
+	Delete(userID int) error
+}
+
+// AvatarModel stores the thumbnails made from each user's profile picture,
+// one row per size. Every change bumps users.avatar_version, which is part of
+// the avatar URLs, so browsers can cache each version forever.
+type AvatarModel struct {
+	DB *sql.DB
+}
+
+// Get returns the user's thumbnail in the given size, or ErrNoRecord if they
+// haven't uploaded a picture.
+func (m *AvatarModel) Get(userID, size int) ([]byte, error) {
+	var image []byte
+
+	err := m.DB.QueryRow("SELECT image FROM avatars WHERE user_id = ? AND size = ?", userID, size).Scan(&image)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// Set replaces the user's thumbnails, which are keyed by size.
+func (m *AvatarModel) Set(userID int, thumbnails map[int][]byte) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE users SET avatar_version = avatar_version + 1, avatar_updated = UTC_TIMESTAMP() WHERE id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM avatars WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	for size, image := range thumbnails {
+		_, err = tx.Exec("INSERT INTO avatars (user_id, size, image) VALUES(?, ?, ?)", userID, size, image)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the user's picture, so they go back to an identicon.
+func (m *AvatarModel) Delete(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET avatar_version = avatar_version + 1, avatar_updated = NULL WHERE id = ? AND avatar_updated IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM avatars WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
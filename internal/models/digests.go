@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DigestRecipient is a user who is due an email digest.
+type DigestRecipient struct {
+	UserID    int
+	Name      string
+	Email     string
+	Frequency string
+	Timezone  string
+	// LastSent is when the user was last sent a digest, or zero if they
+	// never have been.
+	LastSent time.Time
+}
+
+type DigestModelInterface interface {
+	Due() ([]DigestRecipient, error)
+	Sent(userID int, at time.Time) error
+}
+
+// DigestModel keeps track of which users are due an email digest. The
+// frequency is one of the user's preferences.
+type DigestModel struct {
+	DB *sql.DB
+}
+
+// Due returns the users whose last digest is older than their chosen
+// frequency. An hour of slack is allowed, so digests don't drift later every
+// time the job runs slightly early. Suspended users and users who haven't
+// verified their email address are skipped.
+func (m *DigestModel) Due() ([]DigestRecipient, error) {
+	stmt := `SELECT u.id, u.name, u.email, p.digest, p.timezone, p.digest_sent FROM user_preferences p
+    INNER JOIN users u ON u.id = p.user_id
+    WHERE u.suspended IS NULL AND u.email_verified_at IS NOT NULL
+    AND ((p.digest = ? AND (p.digest_sent IS NULL OR p.digest_sent <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL 23 HOUR)))
+    OR (p.digest = ? AND (p.digest_sent IS NULL OR p.digest_sent <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL 167 HOUR))))
+    ORDER BY u.id`
+
+	rows, err := m.DB.Query(stmt, DigestDaily, DigestWeekly)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var recipients []DigestRecipient
+
+	for rows.Next() {
+		var d DigestRecipient
+		var lastSent sql.NullTime
+
+		err = rows.Scan(&d.UserID, &d.Name, &d.Email, &d.Frequency, &d.Timezone, &lastSent)
+		if err != nil {
+			return nil, err
+		}
+
+		d.LastSent = lastSent.Time
+
+		recipients = append(recipients, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// Sent records when the user was last sent a digest.
+func (m *DigestModel) Sent(userID int, at time.Time) error {
+	stmt := `UPDATE user_preferences SET digest_sent = ? WHERE user_id = ?`
+
+	_, err := m.DB.Exec(stmt, at.UTC(), userID)
+	return err
+}
@@ -8,4 +8,16 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("model: duplicate email")
+
+	ErrDuplicateUsername = errors.New("models: duplicate username")
+
+	ErrHidden = errors.New("models: record has been hidden by a moderator")
+
+	ErrSuspended = errors.New("models: account is suspended")
+
+	ErrNoAuthor = errors.New("models: snippet has no author")
+
+	ErrDuplicateIdentity = errors.New("models: identity is already linked to an account")
+
+	ErrInvalidInvite = errors.New("models: invite is invalid, used up or expired")
 )
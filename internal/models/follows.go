@@ -0,0 +1,102 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type FollowModelInterface interface {
+	Follow(followerID, followeeID int) error
+	Unfollow(followerID, followeeID int) error
+	IsFollowing(followerID, followeeID int) (bool, error)
+	Counts(userID int) (following, followers int, err error)
+	Following(userID, before, limit int) ([]User, error)
+	Followers(userID, before, limit int) ([]User, error)
+}
+
+type FollowModel struct {
+	DB *sql.DB
+}
+
+// Follow makes followerID follow followeeID. Following someone twice does
+// nothing.
+func (m *FollowModel) Follow(followerID, followeeID int) error {
+	stmt := `INSERT INTO follows (follower_id, followee_id, followed) VALUES(?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE follower_id = follower_id`
+
+	_, err := m.DB.Exec(stmt, followerID, followeeID)
+	return err
+}
+
+// Unfollow stops followerID following followeeID, if they were.
+func (m *FollowModel) Unfollow(followerID, followeeID int) error {
+	_, err := m.DB.Exec("DELETE FROM follows WHERE follower_id = ? AND followee_id = ?", followerID, followeeID)
+	return err
+}
+
+func (m *FollowModel) IsFollowing(followerID, followeeID int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM follows WHERE follower_id = ? AND followee_id = ?)"
+
+	err := m.DB.QueryRow(stmt, followerID, followeeID).Scan(&exists)
+	return exists, err
+}
+
+// Counts returns how many people the user follows and how many follow them.
+// Suspended users aren't counted.
+func (m *FollowModel) Counts(userID int) (following, followers int, err error) {
+	stmt := `SELECT
+    (SELECT COUNT(*) FROM follows f JOIN users u ON u.id = f.followee_id WHERE f.follower_id = ? AND u.suspended IS NULL),
+    (SELECT COUNT(*) FROM follows f JOIN users u ON u.id = f.follower_id WHERE f.followee_id = ? AND u.suspended IS NULL)`
+
+	err = m.DB.QueryRow(stmt, userID, userID).Scan(&following, &followers)
+	return following, followers, err
+}
+
+// Following returns a page of the people the user follows, ordered by
+// descending user ID. Only users with an ID below before are returned, so
+// the ID of the last user on one page is the before of the next; 0 starts
+// from the top. Suspended users are left out.
+//
+// None of the follows columns share a name with the users table, so
+// userColumns can be used unqualified in the join.
+func (m *FollowModel) Following(userID, before, limit int) ([]User, error) {
+	stmt := "SELECT " + userColumns + ` FROM users JOIN follows ON followee_id = id
+    WHERE follower_id = ? AND suspended IS NULL AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+
+	return m.list(stmt, userID, before, limit)
+}
+
+// Followers is like Following, but returns the people who follow the user.
+func (m *FollowModel) Followers(userID, before, limit int) ([]User, error) {
+	stmt := "SELECT " + userColumns + ` FROM users JOIN follows ON follower_id = id
+    WHERE followee_id = ? AND suspended IS NULL AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+
+	return m.list(stmt, userID, before, limit)
+}
+
+func (m *FollowModel) list(stmt string, userID, before, limit int) ([]User, error) {
+	rows, err := m.DB.Query(stmt, userID, before, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
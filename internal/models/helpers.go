@@ -0,0 +1,36 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcard characters in s so it can be used as a
+// literal inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// checkAffected returns ErrNoRecord if a statement didn't change any rows.
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// hashToken returns the SHA-256 hash of a random token. Only the hash of a
+// token is stored, so a copy of the database can't be used to log in.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
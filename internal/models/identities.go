@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type IdentityModelInterface interface {
+	Get(issuer, subject string) (int, error)
+	Link(userID int, issuer, subject string) error
+}
+
+// IdentityModel links accounts to identities at external OpenID Connect
+// providers. An identity is the pair of the provider's issuer URL and the
+// subject identifier it uses for the user.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// Get returns the ID of the user linked to the identity, or ErrNoRecord.
+func (m *IdentityModel) Get(issuer, subject string) (int, error) {
+	var userID int
+
+	stmt := "SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?"
+
+	err := m.DB.QueryRow(stmt, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Link links the identity to the user. It returns ErrDuplicateIdentity if the
+// identity is already linked to an account.
+func (m *IdentityModel) Link(userID int, issuer, subject string) error {
+	stmt := `INSERT INTO user_identities (user_id, issuer, subject, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, issuer, subject)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "user_identities_uc_identity") {
+				return ErrDuplicateIdentity
+			}
+		}
+		return err
+	}
+
+	return nil
+}
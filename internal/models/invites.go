@@ -0,0 +1,141 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"time"
+)
+
+// Invite is a code which lets people sign up while the site is invite-only.
+type Invite struct {
+	ID        int
+	Code      string
+	CreatedBy int
+	// Creator is the name of the user who created the invite.
+	Creator string
+	MaxUses int
+	Uses    int
+	Expires time.Time
+	Revoked bool
+	Created time.Time
+	// UsedBy lists the names of the users who signed up with the invite.
+	UsedBy []string
+}
+
+// Usable reports whether someone can still sign up with the invite.
+func (i Invite) Usable() bool {
+	return !i.Revoked && i.Uses < i.MaxUses && time.Now().Before(i.Expires)
+}
+
+type InviteModelInterface interface {
+	New(createdBy, maxUses int, expires time.Time) (string, error)
+	List(createdBy int) ([]Invite, error)
+	Revoke(id, createdBy int) error
+}
+
+// InviteModel stores invite codes. Invites are used up by
+// UserModel.InsertWithInvite, which records the invite each user signed up
+// with, and so who invited them.
+type InviteModel struct {
+	DB *sql.DB
+}
+
+// New creates an invite and returns its code.
+func (m *InviteModel) New(createdBy, maxUses int, expires time.Time) (string, error) {
+	code := rand.Text()
+
+	stmt := `INSERT INTO invites (code, created_by, max_uses, uses, expires, created)
+    VALUES(?, ?, ?, 0, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, code, createdBy, maxUses, expires.UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// List returns the invites created by the given user, or everyone's if
+// createdBy is 0, newest first.
+func (m *InviteModel) List(createdBy int) ([]Invite, error) {
+	stmt := `SELECT i.id, i.code, i.created_by, COALESCE(c.name, ''), i.max_uses, i.uses, i.expires,
+    i.revoked IS NOT NULL, i.created
+    FROM invites i LEFT JOIN users c ON c.id = i.created_by
+    WHERE ? = 0 OR i.created_by = ? ORDER BY i.id DESC LIMIT 100`
+
+	rows, err := m.DB.Query(stmt, createdBy, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var invites []Invite
+	index := make(map[int]int)
+
+	for rows.Next() {
+		var i Invite
+
+		err = rows.Scan(&i.ID, &i.Code, &i.CreatedBy, &i.Creator, &i.MaxUses, &i.Uses, &i.Expires, &i.Revoked, &i.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		index[i.ID] = len(invites)
+		invites = append(invites, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(invites) == 0 {
+		return nil, nil
+	}
+
+	// Fill in who signed up with each invite. Only the last invite's ID is
+	// needed as a lower bound, since the list is sorted newest first.
+	stmt = `SELECT invite_id, name FROM users WHERE invite_id >= ? ORDER BY id`
+
+	rows, err = m.DB.Query(stmt, invites[len(invites)-1].ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var inviteID int
+		var name string
+
+		err = rows.Scan(&inviteID, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		if i, ok := index[inviteID]; ok {
+			invites[i].UsedBy = append(invites[i].UsedBy, name)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// Revoke stops an invite from being used again. If createdBy isn't 0, the
+// invite must have been created by that user. It returns ErrNoRecord if
+// there's no such invite which can still be used.
+func (m *InviteModel) Revoke(id, createdBy int) error {
+	stmt := `UPDATE invites SET revoked = UTC_TIMESTAMP()
+    WHERE id = ? AND (? = 0 OR created_by = ?) AND revoked IS NULL AND uses < max_uses`
+
+	result, err := m.DB.Exec(stmt, id, createdBy, createdBy)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
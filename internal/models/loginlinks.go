@@ -0,0 +1,82 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type LoginLinkModelInterface interface {
+	New(userID int, nonce string, ttl time.Duration) (string, error)
+	Use(token, nonce string) (int, error)
+}
+
+// LoginLinkModel stores one-time tokens for logging in from a link in an
+// email instead of with a password. Each token is bound to a nonce kept in
+// the session of the browser which asked for it, so the link only works in
+// that browser. Only SHA-256 hashes of the token and the nonce are stored.
+type LoginLinkModel struct {
+	DB *sql.DB
+}
+
+// New creates a login link token for the user which expires after ttl, and
+// returns the plaintext token to be emailed to them.
+func (m *LoginLinkModel) New(userID int, nonce string, ttl time.Duration) (string, error) {
+	token := rand.Text()
+
+	stmt := `INSERT INTO login_links (token_hash, nonce_hash, user_id, created, expires)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err := m.DB.Exec(stmt, hashToken(token), hashToken(nonce), userID, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Use returns the ID of the user a token belongs to and deletes it, along
+// with any other outstanding tokens for the same user. It returns
+// ErrNoRecord if the token doesn't exist, has expired or was asked for with
+// a different nonce. Tokens aren't used up by a wrong nonce, so a link which
+// is opened somewhere else first, for example by a mail scanner, still works
+// in the right browser.
+func (m *LoginLinkModel) Use(token, nonce string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var userID int
+	var nonceHash []byte
+
+	stmt := `SELECT user_id, nonce_hash FROM login_links
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&userID, &nonceHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	if subtle.ConstantTimeCompare(nonceHash, hashToken(nonce)) != 1 {
+		return 0, ErrNoRecord
+	}
+
+	_, err = tx.Exec("DELETE FROM login_links WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
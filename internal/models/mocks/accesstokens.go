@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// Personal access tokens belonging to user 1.
+const (
+	ReadWriteAccessToken = "sbx_READWRITE"
+	ReadOnlyAccessToken  = "sbx_READONLY"
+	NewAccessToken       = "sbx_NEWTOKEN"
+)
+
+var mockAccessTokens = map[string]models.AccessToken{
+	ReadWriteAccessToken: {
+		ID:      1,
+		UserID:  1,
+		Name:    "CI",
+		Scopes:  []string{models.ScopeRead, models.ScopeWrite},
+		Created: time.Now().Add(-24 * time.Hour),
+	},
+	ReadOnlyAccessToken: {
+		ID:      2,
+		UserID:  1,
+		Name:    "Dashboard",
+		Scopes:  []string{models.ScopeRead},
+		Created: time.Now().Add(-48 * time.Hour),
+		Expires: time.Now().Add(24 * time.Hour),
+	},
+}
+
+type AccessTokenModel struct{}
+
+func (m *AccessTokenModel) New(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	return NewAccessToken, nil
+}
+
+func (m *AccessTokenModel) Authenticate(token string) (models.AccessToken, error) {
+	t, ok := mockAccessTokens[token]
+	if !ok {
+		return models.AccessToken{}, models.ErrNoRecord
+	}
+	return t, nil
+}
+
+func (m *AccessTokenModel) List(userID int) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	for _, token := range []string{ReadWriteAccessToken, ReadOnlyAccessToken} {
+		if t := mockAccessTokens[token]; t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *AccessTokenModel) Revoke(userID, id int) error {
+	for _, t := range mockAccessTokens {
+		if t.ID == id && t.UserID == userID {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
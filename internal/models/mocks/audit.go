@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+var mockAuditEvent = models.AuditEvent{
+	ID:        1,
+	UserID:    1,
+	Event:     models.AuditLogin,
+	IP:        "192.0.2.1",
+	UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0",
+	Created:   time.Now().Add(-time.Hour),
+	User:      "Alice",
+}
+
+// AuditModel keeps the events recorded during a test in memory, after one
+// earlier login by user 1.
+type AuditModel struct {
+	mu     sync.Mutex
+	Events []models.AuditEvent
+}
+
+func (m *AuditModel) Record(event models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = len(m.Events) + 2
+	event.Created = time.Now()
+	m.Events = append(m.Events, event)
+	return nil
+}
+
+func (m *AuditModel) all() []models.AuditEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := append([]models.AuditEvent{mockAuditEvent}, m.Events...)
+	slices.Reverse(events)
+	return events
+}
+
+func (m *AuditModel) ForUser(userID, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, e := range m.all() {
+		if e.UserID == userID && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *AuditModel) Search(query string, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, e := range m.all() {
+		match := query == "" || e.Event == query || strings.Contains(e.Detail, query) || strings.Contains(e.IP, query)
+		if match && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
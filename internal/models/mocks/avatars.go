@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// AvatarImage is the picture user 1 has uploaded, in every size.
+var AvatarImage = []byte("\x89PNG\r\n\x1a\nmock avatar")
+
+type AvatarModel struct{}
+
+func (m *AvatarModel) Get(userID, size int) ([]byte, error) {
+	if userID == 1 {
+		return AvatarImage, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *AvatarModel) Set(userID int, thumbnails map[int][]byte) error {
+	return nil
+}
+
+func (m *AvatarModel) Delete(userID int) error {
+	return nil
+}
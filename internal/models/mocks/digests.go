@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// DigestModel has Alice due a daily digest and Bob due a weekly one, each
+// until one is sent to them.
+type DigestModel struct {
+	mu   sync.Mutex
+	sent map[int]time.Time
+}
+
+func (m *DigestModel) Due() ([]models.DigestRecipient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recipients := []models.DigestRecipient{{
+		UserID:    1,
+		Name:      "Alice",
+		Email:     "alice@example.com",
+		Frequency: models.DigestDaily,
+		Timezone:  "Europe/Paris",
+	}, {
+		UserID:    2,
+		Name:      "Bob",
+		Email:     "bob@example.com",
+		Frequency: models.DigestWeekly,
+		Timezone:  "UTC",
+		LastSent:  time.Now().Add(-8 * 24 * time.Hour),
+	}}
+
+	var due []models.DigestRecipient
+	for _, d := range recipients {
+		if _, ok := m.sent[d.UserID]; !ok {
+			due = append(due, d)
+		}
+	}
+
+	return due, nil
+}
+
+func (m *DigestModel) Sent(userID int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sent == nil {
+		m.sent = make(map[int]time.Time)
+	}
+	m.sent[userID] = at
+	return nil
+}
@@ -0,0 +1,100 @@
+package mocks
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// FollowModel keeps follows in memory. Bob starts out following Alice.
+type FollowModel struct {
+	mu      sync.Mutex
+	follows [][2]int
+	seeded  bool
+}
+
+func (m *FollowModel) seed() {
+	if m.seeded {
+		return
+	}
+	m.seeded = true
+	m.follows = append(m.follows, [2]int{2, 1})
+}
+
+func (m *FollowModel) Follow(followerID, followeeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	if !slices.Contains(m.follows, [2]int{followerID, followeeID}) {
+		m.follows = append(m.follows, [2]int{followerID, followeeID})
+	}
+	return nil
+}
+
+func (m *FollowModel) Unfollow(followerID, followeeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	m.follows = slices.DeleteFunc(m.follows, func(f [2]int) bool {
+		return f == [2]int{followerID, followeeID}
+	})
+	return nil
+}
+
+func (m *FollowModel) IsFollowing(followerID, followeeID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	return slices.Contains(m.follows, [2]int{followerID, followeeID}), nil
+}
+
+func (m *FollowModel) Counts(userID int) (int, int, error) {
+	following, _ := m.Following(userID, 0, 100)
+	followers, _ := m.Followers(userID, 0, 100)
+	return len(following), len(followers), nil
+}
+
+func (m *FollowModel) Following(userID, before, limit int) ([]models.User, error) {
+	return m.list(before, limit, func(f [2]int) (int, bool) {
+		return f[1], f[0] == userID
+	})
+}
+
+func (m *FollowModel) Followers(userID, before, limit int) ([]models.User, error) {
+	return m.list(before, limit, func(f [2]int) (int, bool) {
+		return f[0], f[1] == userID
+	})
+}
+
+// list returns the users picked out by match, by descending ID.
+func (m *FollowModel) list(before, limit int, match func([2]int) (int, bool)) ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	var ids []int
+	for _, f := range m.follows {
+		if id, ok := match(f); ok && (before == 0 || id < before) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+
+	var users []models.User
+	for _, id := range ids {
+		if len(users) == limit {
+			break
+		}
+		user, err := (&UserModel{}).Get(id)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,33 @@
+package mocks
+
+import (
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// LinkedSubject is the subject of an external identity which is already
+// linked to user 2, at any issuer.
+const LinkedSubject = "bob-at-idp"
+
+// DeletedUserSubject is the subject of an external identity which is still
+// linked to a user who no longer exists.
+const DeletedUserSubject = "deleted-at-idp"
+
+type IdentityModel struct{}
+
+func (m *IdentityModel) Get(issuer, subject string) (int, error) {
+	switch subject {
+	case LinkedSubject:
+		return 2, nil
+	case DeletedUserSubject:
+		return 99, nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+func (m *IdentityModel) Link(userID int, issuer, subject string) error {
+	if subject == LinkedSubject {
+		return models.ErrDuplicateIdentity
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// ValidInviteCode is the only invite code the mocks accept.
+const ValidInviteCode = "VALIDINVITE"
+
+// NewInviteCode is returned for every invite created with the mock.
+const NewInviteCode = "NEWINVITE"
+
+var mockInvites = []models.Invite{
+	{
+		ID:        2,
+		Code:      ValidInviteCode,
+		CreatedBy: 1,
+		Creator:   "Alice",
+		MaxUses:   5,
+		Uses:      1,
+		Expires:   time.Now().Add(7 * 24 * time.Hour),
+		Created:   time.Now().Add(-24 * time.Hour),
+		UsedBy:    []string{"Dave"},
+	},
+	{
+		ID:        1,
+		Code:      "USEDUPINVITE",
+		CreatedBy: 3,
+		Creator:   "Carol",
+		MaxUses:   1,
+		Uses:      1,
+		Expires:   time.Now().Add(24 * time.Hour),
+		Created:   time.Now().Add(-48 * time.Hour),
+		UsedBy:    []string{"Bob"},
+	},
+}
+
+type InviteModel struct{}
+
+func (m *InviteModel) New(createdBy, maxUses int, expires time.Time) (string, error) {
+	return NewInviteCode, nil
+}
+
+func (m *InviteModel) List(createdBy int) ([]models.Invite, error) {
+	var invites []models.Invite
+	for _, i := range mockInvites {
+		if createdBy == 0 || i.CreatedBy == createdBy {
+			invites = append(invites, i)
+		}
+	}
+	return invites, nil
+}
+
+func (m *InviteModel) Revoke(id, createdBy int) error {
+	for _, i := range mockInvites {
+		if i.ID == id && (createdBy == 0 || i.CreatedBy == createdBy) && i.Usable() {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
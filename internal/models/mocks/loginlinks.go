@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// LoginLinkToken is the token the mock returns for every new login link.
+const LoginLinkToken = "LOGINLINKTOKEN"
+
+type mockLoginLink struct {
+	userID int
+	nonce  string
+}
+
+// LoginLinkModel remembers the login link created during a test, so it can
+// only be used once and with the nonce it was created with.
+type LoginLinkModel struct {
+	mu   sync.Mutex
+	link *mockLoginLink
+}
+
+func (m *LoginLinkModel) New(userID int, nonce string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.link = &mockLoginLink{userID: userID, nonce: nonce}
+	return LoginLinkToken, nil
+}
+
+func (m *LoginLinkModel) Use(token, nonce string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token != LoginLinkToken || m.link == nil || m.link.nonce != nonce {
+		return 0, models.ErrNoRecord
+	}
+
+	userID := m.link.userID
+	m.link = nil
+	return userID, nil
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+var mockReport = models.Report{
+	ID:           1,
+	SnippetID:    1,
+	SnippetTitle: "An old silent pond",
+	AuthorID:     1,
+	ReporterID:   2,
+	Reason:       "spam",
+	Note:         "Looks like an advert",
+	Created:      time.Now(),
+}
+
+type ModerationModel struct{}
+
+func (m *ModerationModel) Report(snippetID, reporterID int, reason, note string) (int, error) {
+	return 1, nil
+}
+
+func (m *ModerationModel) OpenReports() ([]models.Report, error) {
+	return []models.Report{mockReport}, nil
+}
+
+func (m *ModerationModel) Resolve(reportID, moderatorID int, action string) (models.Report, error) {
+	if reportID != 1 {
+		return models.Report{}, models.ErrNoRecord
+	}
+	return mockReport, nil
+}
+
+func (m *ModerationModel) Log(limit int) ([]models.ModerationEvent, error) {
+	return nil, nil
+}
+
+func (m *ModerationModel) Record(moderatorID int, action string, snippetID, userID int) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// NotificationModel keeps notifications in memory. User 1 starts with one
+// unread notification.
+type NotificationModel struct {
+	mu            sync.Mutex
+	Notifications []models.Notification
+	seeded        bool
+}
+
+func (m *NotificationModel) seed() {
+	if m.seeded {
+		return
+	}
+	m.seeded = true
+	m.Notifications = append([]models.Notification{{
+		ID:      1,
+		UserID:  1,
+		Kind:    models.NotifySnippetExpiring,
+		Message: `Your snippet "An old silent pond" expires soon`,
+		Link:    "/snippet/view/1/",
+		Key:     "snippet-expiring:1",
+		Created: time.Now().Add(-time.Hour),
+	}}, m.Notifications...)
+}
+
+func (m *NotificationModel) Insert(n models.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	for _, existing := range m.Notifications {
+		if n.Key != "" && existing.UserID == n.UserID && existing.Key == n.Key {
+			return nil
+		}
+	}
+
+	n.ID = len(m.Notifications) + 1
+	n.Created = time.Now()
+	m.Notifications = append(m.Notifications, n)
+	return nil
+}
+
+func (m *NotificationModel) ForUser(userID, limit int) ([]models.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	var notifications []models.Notification
+	for i := len(m.Notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		if m.Notifications[i].UserID == userID {
+			notifications = append(notifications, m.Notifications[i])
+		}
+	}
+	return notifications, nil
+}
+
+func (m *NotificationModel) Unread(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	count := 0
+	for _, n := range m.Notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	for i, n := range m.Notifications {
+		if n.ID == id && n.UserID == userID {
+			m.Notifications[i].Read = true
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed()
+
+	for i, n := range m.Notifications {
+		if n.UserID == userID {
+			m.Notifications[i].Read = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// ValidResetToken is the only token the mock accepts. It always belongs to
+// user 1.
+const ValidResetToken = "VALIDRESETTOKEN"
+
+type PasswordResetModel struct{}
+
+func (m *PasswordResetModel) New(userID int, ttl time.Duration) (string, error) {
+	return ValidResetToken, nil
+}
+
+func (m *PasswordResetModel) Check(token string) (int, error) {
+	if token == ValidResetToken {
+		return 1, nil
+	}
+	return 0, models.ErrNoRecord
+}
+
+func (m *PasswordResetModel) Reset(token, newPassword string) (int, error) {
+	return m.Check(token)
+}
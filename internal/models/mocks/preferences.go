@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// PreferenceModel keeps preferences in memory. User 2 starts with
+// preferences of their own; everyone else has the defaults.
+type PreferenceModel struct {
+	mu    sync.Mutex
+	prefs map[int]models.Preferences
+}
+
+func (m *PreferenceModel) Get(userID int) (models.Preferences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.prefs[userID]; ok {
+		return p, nil
+	}
+	if userID == 2 {
+		return models.Preferences{Timezone: "America/New_York", DefaultExpires: 7, Theme: models.ThemeDark, PageSize: 25, Digest: models.DigestOff}, nil
+	}
+	return models.DefaultPreferences(), nil
+}
+
+func (m *PreferenceModel) Update(userID int, prefs models.Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.prefs == nil {
+		m.prefs = make(map[int]models.Preferences)
+	}
+	m.prefs[userID] = prefs
+	return nil
+}
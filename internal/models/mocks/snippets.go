@@ -8,22 +8,33 @@ import (
 
 var mockSnippet = models.Snippet{
 	ID:      1,
+	UserID:  1,
 	Title:   "An old silent pond",
 	Content: "An old silent pond...",
 	Created: time.Now(),
-	Expires: time.Now(),
+	Expires: time.Now().Add(24 * time.Hour),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(userID int, entry models.SnippetEntry) (int, error) {
 	return 2, nil
 }
 
+func (m *SnippetModel) InsertMany(userID int, entries []models.SnippetEntry) ([]int, error) {
+	ids := make([]int, len(entries))
+	for i := range entries {
+		ids[i] = i + 2
+	}
+	return ids, nil
+}
+
 func (m *SnippetModel) Get(id int) (models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return models.Snippet{}, models.ErrHidden
 
 	default:
 		return models.Snippet{}, models.ErrNoRecord
@@ -33,3 +44,40 @@ func (m *SnippetModel) Get(id int) (models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]models.Snippet, error) {
 	return []models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(userID, limit, offset int) ([]models.Snippet, error) {
+	if userID != mockSnippet.UserID || offset > 0 {
+		return nil, nil
+	}
+	return []models.Snippet{mockSnippet}, nil
+}
+
+// Feed returns the mock snippet to Bob, who follows its author.
+func (m *SnippetModel) Feed(userID, before, limit int) ([]models.Snippet, error) {
+	if userID != 2 || (before != 0 && before <= mockSnippet.ID) {
+		return nil, nil
+	}
+	return []models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Expiring(within time.Duration) ([]models.Snippet, error) {
+	return []models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Search(query string, limit int) ([]models.Snippet, error) {
+	return []models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Renew(id, userID int, expires time.Time, days int) error {
+	if id != mockSnippet.ID || userID != mockSnippet.UserID || expires.Unix() != mockSnippet.Expires.Unix() {
+		return models.ErrNoRecord
+	}
+	return nil
+}
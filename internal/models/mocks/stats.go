@@ -0,0 +1,14 @@
+package mocks
+
+import "github.com/markponce/snippetbox/internal/models"
+
+type StatsModel struct{}
+
+func (m *StatsModel) Get() (models.Stats, error) {
+	return models.Stats{
+		Users:          3,
+		Snippets:       1,
+		ActiveSnippets: 1,
+		OpenReports:    1,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"github.com/markponce/snippetbox/internal/models"
+)
+
+// TOTPSecret is the two-factor secret of user 5, the only mock user with
+// two-factor authentication turned on.
+const TOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// RecoveryCode is a valid recovery code for user 5.
+const RecoveryCode = "ABCDE-FGHIJ"
+
+type TwoFactorModel struct{}
+
+func (m *TwoFactorModel) Secret(userID int) (string, error) {
+	if userID == 5 {
+		return TOTPSecret, nil
+	}
+	return "", models.ErrNoRecord
+}
+
+func (m *TwoFactorModel) Enable(userID int, secret string) ([]string, error) {
+	codes := make([]string, models.RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = RecoveryCode
+	}
+	return codes, nil
+}
+
+func (m *TwoFactorModel) Disable(userID int) error {
+	return nil
+}
+
+func (m *TwoFactorModel) UseStep(userID int, step int64) error {
+	return nil
+}
+
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	if userID == 5 && code == RecoveryCode {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	return models.RecoveryCodeCount, nil
+}
@@ -8,26 +8,57 @@ import (
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, username, email, password string) (int, error) {
+	if _, err := m.GetByUsername(username); err == nil {
+		return 0, models.ErrDuplicateUsername
+	}
+
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 4, nil
 	}
 }
 
+func (m *UserModel) InsertWithInvite(name, username, email, password, inviteCode string) (int, error) {
+	if inviteCode != ValidInviteCode {
+		return 0, models.ErrInvalidInvite
+	}
+	return m.Insert(name, username, email, password)
+}
+
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
 
+	if email == "bob@example.com" && password == "pa$$word" {
+		return 2, nil
+	}
+
+	if email == "carol@example.com" && password == "pa$$word" {
+		return 3, nil
+	}
+
+	if email == "dave@example.com" && password == "pa$$word" {
+		return 4, nil
+	}
+
+	if email == "erin@example.com" && password == "pa$$word" {
+		return 5, nil
+	}
+
+	if email == "fiona@example.com" && password == "pa$$word" {
+		return 6, nil
+	}
+
 	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2, 3, 4, 5, 6:
 		return true, nil
 	default:
 		return false, nil
@@ -35,16 +66,157 @@ func (m *UserModel) Exists(id int) (bool, error) {
 }
 
 func (m *UserModel) Get(id int) (models.User, error) {
-	if id == 1 {
+	switch id {
+	case 1:
 		return models.User{
-			Name:    "Alice",
-			Email:   "alice@example.com",
-			Created: time.Now().Add(-4 * 24 * time.Hour),
+			ID:            1,
+			Name:          "Alice",
+			Username:      "alice",
+			Bio:           "Haiku enthusiast.",
+			HasAvatar:     true,
+			AvatarVersion: 3,
+			Email:         "alice@example.com",
+			Created:       time.Now().Add(-4 * 24 * time.Hour),
+			Role:          models.RoleUser,
+			EmailVerified: true,
 		}, nil
+	case 2:
+		return models.User{
+			ID:            2,
+			Name:          "Bob",
+			Username:      "bob",
+			Email:         "bob@example.com",
+			Created:       time.Now().Add(-8 * 24 * time.Hour),
+			Role:          models.RoleModerator,
+			EmailVerified: true,
+		}, nil
+	case 3:
+		return models.User{
+			ID:            3,
+			Name:          "Carol",
+			Username:      "carol",
+			Email:         "carol@example.com",
+			Created:       time.Now().Add(-16 * 24 * time.Hour),
+			Role:          models.RoleAdmin,
+			EmailVerified: true,
+		}, nil
+	case 4:
+		return models.User{
+			ID:      4,
+			Name:    "Dave",
+			Email:   "dave@example.com",
+			Created: time.Now().Add(-1 * time.Hour),
+			Role:    models.RoleUser,
+		}, nil
+	case 5:
+		return models.User{
+			ID:               5,
+			Name:             "Erin",
+			Username:         "erin",
+			Email:            "erin@example.com",
+			Created:          time.Now().Add(-32 * 24 * time.Hour),
+			Role:             models.RoleUser,
+			EmailVerified:    true,
+			TwoFactorEnabled: true,
+		}, nil
+	case 6:
+		return models.User{
+			ID:            6,
+			Name:          "Fiona",
+			Username:      "fiona",
+			Email:         "fiona@example.com",
+			Created:       time.Now().Add(-64 * 24 * time.Hour),
+			Role:          models.RoleUser,
+			EmailVerified: true,
+			DeleteAfter:   time.Now().Add(7 * 24 * time.Hour),
+		}, nil
+	default:
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByEmail(email string) (models.User, error) {
+	for id := 1; id <= 6; id++ {
+		user, _ := m.Get(id)
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, models.ErrNoRecord
+}
+
+func (m *UserModel) GetByUsername(username string) (models.User, error) {
+	for id := 1; id <= 6; id++ {
+		user, _ := m.Get(id)
+		if user.Username != "" && user.Username == username {
+			return user, nil
+		}
 	}
 	return models.User{}, models.ErrNoRecord
 }
 
+func (m *UserModel) UpdateProfile(id int, username, bio string) error {
+	user, err := m.GetByUsername(username)
+	if err == nil && user.ID != id {
+		return models.ErrDuplicateUsername
+	}
+	return nil
+}
+
 func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
 	return nil
 }
+
+func (m *UserModel) Search(query string, limit int) ([]models.User, error) {
+	var users []models.User
+	for id := 1; id <= 6; id++ {
+		user, _ := m.Get(id)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *UserModel) VerifyEmail(id int, email string) error {
+	user, err := m.Get(id)
+	if err != nil || user.Email != email {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *UserModel) ChangeEmail(id int, oldEmail, newEmail string) error {
+	user, err := m.Get(id)
+	if err != nil || user.Email != oldEmail {
+		return models.ErrNoRecord
+	}
+	if _, err := m.GetByEmail(newEmail); err == nil || newEmail == "dupe@example.com" {
+		return models.ErrDuplicateEmail
+	}
+	return nil
+}
+
+func (m *UserModel) ScheduleDeletion(id int, password string, after time.Duration, deleteSnippets bool) error {
+	if _, err := m.Get(id); err != nil || password != "pa$$word" {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (m *UserModel) CancelDeletion(id int) (bool, error) {
+	user, err := m.Get(id)
+	if err != nil {
+		return false, nil
+	}
+	return !user.DeleteAfter.IsZero(), nil
+}
+
+func (m *UserModel) DeleteScheduled() ([]int, error) {
+	return nil, nil
+}
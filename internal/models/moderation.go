@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Actions a moderator can take on a report.
+const (
+	ModerationHide    = "hide"
+	ModerationDelete  = "delete"
+	ModerationDismiss = "dismiss"
+	ModerationSuspend = "suspend"
+)
+
+// Actions an administrator can take directly, without a report.
+const (
+	AdminSuspend       = "suspend"
+	AdminUnsuspend     = "unsuspend"
+	AdminResetSessions = "reset-sessions"
+	AdminDeleteSnippet = "delete"
+	AdminUnlock        = "unlock"
+)
+
+// ReportReasons are the reasons a user can pick when reporting a snippet.
+var ReportReasons = []string{"spam", "abuse", "secrets", "illegal", "other"}
+
+type Report struct {
+	ID           int
+	SnippetID    int
+	SnippetTitle string
+	AuthorID     int
+	ReporterID   int
+	Reason       string
+	Note         string
+	Created      time.Time
+}
+
+// ModerationEvent is a single entry in the moderation audit log.
+type ModerationEvent struct {
+	ID          int
+	ModeratorID int
+	Moderator   string
+	Action      string
+	ReportID    int
+	SnippetID   int
+	UserID      int
+	Created     time.Time
+}
+
+type ModerationModelInterface interface {
+	Report(snippetID, reporterID int, reason, note string) (int, error)
+	OpenReports() ([]Report, error)
+	Resolve(reportID, moderatorID int, action string) (Report, error)
+	Log(limit int) ([]ModerationEvent, error)
+	Record(moderatorID int, action string, snippetID, userID int) error
+}
+
+type ModerationModel struct {
+	DB *sql.DB
+}
+
+// Report files a new open report against a snippet.
+func (m *ModerationModel) Report(snippetID, reporterID int, reason, note string) (int, error) {
+	stmt := `INSERT INTO reports (snippet_id, reporter_id, reason, note, status, created)
+    VALUES(?, ?, ?, ?, 'open', UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, snippetID, reporterID, reason, note)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// OpenReports returns every report which hasn't been acted on yet, oldest
+// first. Reports for snippets which no longer exist are left out.
+func (m *ModerationModel) OpenReports() ([]Report, error) {
+	stmt := `SELECT r.id, r.snippet_id, s.title, s.user_id, r.reporter_id, r.reason, r.note, r.created
+    FROM reports r INNER JOIN snippets s ON s.id = r.snippet_id
+    WHERE r.status = 'open' ORDER BY r.id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var reports []Report
+
+	for rows.Next() {
+		var r Report
+		var authorID sql.NullInt64
+
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.SnippetTitle, &authorID, &r.ReporterID, &r.Reason, &r.Note, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		r.AuthorID = int(authorID.Int64)
+		reports = append(reports, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// Resolve applies a moderation action to an open report. The action, the
+// report status change and the audit log entry are written in a single
+// transaction. Hiding or deleting a snippet also closes any other open reports
+// for the same snippet. It returns the report as it was before it was
+// resolved.
+func (m *ModerationModel) Resolve(reportID, moderatorID int, action string) (Report, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Report{}, err
+	}
+
+	defer tx.Rollback()
+
+	report := Report{ID: reportID}
+	var authorID sql.NullInt64
+
+	stmt := `SELECT r.snippet_id, s.title, s.user_id, r.reporter_id, r.reason, r.note, r.created FROM reports r
+    INNER JOIN snippets s ON s.id = r.snippet_id
+    WHERE r.id = ? AND r.status = 'open' FOR UPDATE`
+
+	err = tx.QueryRow(stmt, reportID).Scan(&report.SnippetID, &report.SnippetTitle, &authorID, &report.ReporterID, &report.Reason, &report.Note, &report.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Report{}, ErrNoRecord
+		}
+		return Report{}, err
+	}
+
+	report.AuthorID = int(authorID.Int64)
+	snippetID := report.SnippetID
+
+	var targetUserID any
+
+	switch action {
+	case ModerationHide:
+		_, err = tx.Exec("UPDATE snippets SET hidden = UTC_TIMESTAMP() WHERE id = ?", snippetID)
+	case ModerationDelete:
+		_, err = tx.Exec("DELETE FROM snippets WHERE id = ?", snippetID)
+	case ModerationDismiss:
+	case ModerationSuspend:
+		if !authorID.Valid {
+			return Report{}, ErrNoAuthor
+		}
+		targetUserID = authorID.Int64
+		_, err = tx.Exec("UPDATE users SET suspended = UTC_TIMESTAMP() WHERE id = ? AND suspended IS NULL", authorID.Int64)
+	default:
+		return Report{}, errors.New("models: unknown moderation action " + action)
+	}
+	if err != nil {
+		return Report{}, err
+	}
+
+	if action == ModerationHide || action == ModerationDelete {
+		stmt = `UPDATE reports SET status = ?, resolved = UTC_TIMESTAMP(), resolved_by = ?
+        WHERE snippet_id = ? AND status = 'open'`
+		_, err = tx.Exec(stmt, action, moderatorID, snippetID)
+	} else {
+		stmt = `UPDATE reports SET status = ?, resolved = UTC_TIMESTAMP(), resolved_by = ?
+        WHERE id = ?`
+		_, err = tx.Exec(stmt, action, moderatorID, reportID)
+	}
+	if err != nil {
+		return Report{}, err
+	}
+
+	stmt = `INSERT INTO moderation_log (moderator_id, action, report_id, snippet_id, user_id, created)
+    VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, moderatorID, action, reportID, snippetID, targetUserID)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Report{}, err
+	}
+
+	return report, nil
+}
+
+// Record adds an entry to the audit log for an action which wasn't taken in
+// response to a report. A zero snippetID or userID is stored as NULL.
+func (m *ModerationModel) Record(moderatorID int, action string, snippetID, userID int) error {
+	stmt := `INSERT INTO moderation_log (moderator_id, action, report_id, snippet_id, user_id, created)
+    VALUES(?, ?, NULL, NULLIF(?, 0), NULLIF(?, 0), UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, moderatorID, action, snippetID, userID)
+	return err
+}
+
+// Log returns the most recent moderation actions, newest first.
+func (m *ModerationModel) Log(limit int) ([]ModerationEvent, error) {
+	// Moderators whose accounts have since been deleted keep their entries.
+	stmt := `SELECT l.id, l.moderator_id, COALESCE(u.name, '(deleted user)'), l.action, l.report_id, l.snippet_id, l.user_id, l.created
+    FROM moderation_log l LEFT JOIN users u ON u.id = l.moderator_id
+    ORDER BY l.id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []ModerationEvent
+
+	for rows.Next() {
+		var e ModerationEvent
+		var reportID, snippetID, userID sql.NullInt64
+
+		err = rows.Scan(&e.ID, &e.ModeratorID, &e.Moderator, &e.Action, &reportID, &snippetID, &userID, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		e.ReportID = int(reportID.Int64)
+		e.SnippetID = int(snippetID.Int64)
+		e.UserID = int(userID.Int64)
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
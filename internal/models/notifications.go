@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of notification.
+const (
+	NotifyReportResolved   = "report-resolved"
+	NotifySnippetModerated = "snippet-moderated"
+	NotifySnippetExpiring  = "snippet-expiring"
+)
+
+type Notification struct {
+	ID      int
+	UserID  int
+	Kind    string
+	Message string
+	// Link is an optional path the notification points to.
+	Link string
+	// Key, if set, makes the notification unique per user: inserting
+	// another one with the same key does nothing. It's used for
+	// notifications which could otherwise be sent more than once.
+	Key     string
+	Read    bool
+	Created time.Time
+}
+
+type NotificationModelInterface interface {
+	Insert(n Notification) error
+	ForUser(userID, limit int) ([]Notification, error)
+	Unread(userID int) (int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) error
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a notification for n.UserID, unless one with the same Key
+// already exists.
+func (m *NotificationModel) Insert(n Notification) error {
+	stmt := `INSERT INTO notifications (user_id, kind, message, link, dedupe_key, created)
+    VALUES(?, ?, ?, ?, NULLIF(?, ''), UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE id = id`
+
+	_, err := m.DB.Exec(stmt, n.UserID, n.Kind, n.Message, n.Link, n.Key)
+	return err
+}
+
+// ForUser returns the user's most recent notifications, newest first.
+func (m *NotificationModel) ForUser(userID, limit int) ([]Notification, error) {
+	stmt := `SELECT id, user_id, kind, message, link, COALESCE(dedupe_key, ''), read_at IS NOT NULL, created
+    FROM notifications WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var notifications []Notification
+
+	for rows.Next() {
+		var n Notification
+
+		err = rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Message, &n.Link, &n.Key, &n.Read, &n.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// Unread returns the number of notifications the user hasn't read yet.
+func (m *NotificationModel) Unread(userID int) (int, error) {
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&n)
+	return n, err
+}
+
+// MarkRead marks one of the user's notifications as read. It returns
+// ErrNoRecord if the user has no such notification.
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM notifications WHERE id = ? AND user_id = ?)`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt = `UPDATE notifications SET read_at = UTC_TIMESTAMP() WHERE id = ? AND read_at IS NULL`
+
+	_, err = m.DB.Exec(stmt, id)
+	return err
+}
+
+// MarkAllRead marks all the user's notifications as read.
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	stmt := `UPDATE notifications SET read_at = UTC_TIMESTAMP() WHERE user_id = ? AND read_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
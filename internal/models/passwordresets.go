@@ -0,0 +1,103 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type PasswordResetModelInterface interface {
+	New(userID int, ttl time.Duration) (string, error)
+	Check(token string) (int, error)
+	Reset(token, newPassword string) (int, error)
+}
+
+// PasswordResetModel stores one-time tokens for resetting a forgotten
+// password. Only a SHA-256 hash of each token is stored, so a copy of the
+// table can't be used to reset anyone's password.
+type PasswordResetModel struct {
+	DB     *sql.DB
+	Hasher PasswordHasher
+}
+
+// New creates a reset token for the user which expires after ttl, and
+// returns the plaintext token to be emailed to them.
+func (m *PasswordResetModel) New(userID int, ttl time.Duration) (string, error) {
+	token := rand.Text()
+
+	stmt := `INSERT INTO password_resets (token_hash, user_id, created, expires)
+    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err := m.DB.Exec(stmt, hashToken(token), userID, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Check returns the ID of the user a token belongs to, or ErrNoRecord if the
+// token doesn't exist, has expired or has already been used.
+func (m *PasswordResetModel) Check(token string) (int, error) {
+	var userID int
+
+	stmt := `SELECT user_id FROM password_resets
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP()`
+
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Reset sets a new password for the user a token belongs to and returns their
+// ID. The token, and any other outstanding tokens for the same user, are
+// deleted in the same transaction so each one can only be used once.
+func (m *PasswordResetModel) Reset(token, newPassword string) (int, error) {
+	hashedPassword, err := m.Hasher.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM password_resets
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", hashedPassword, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// Themes lists every theme users can choose.
+var Themes = []string{ThemeLight, ThemeDark}
+
+// How often users get an email digest of their snippets.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Digests lists every digest frequency users can choose.
+var Digests = []string{DigestOff, DigestDaily, DigestWeekly}
+
+// Preferences are the per-user settings for how the site looks and behaves.
+type Preferences struct {
+	// Timezone is an IANA time zone name, like "Europe/Paris".
+	Timezone string
+	// DefaultExpires is the number of days pre-selected when creating a
+	// snippet.
+	DefaultExpires int
+	Theme          string
+	// PageSize is the number of snippets on each page of a listing.
+	PageSize int
+	// Digest is how often the user is emailed a digest.
+	Digest string
+}
+
+// DefaultPreferences are used for anonymous visitors and for users who
+// haven't saved any preferences.
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Timezone:       "UTC",
+		DefaultExpires: 365,
+		Theme:          ThemeLight,
+		PageSize:       10,
+		Digest:         DigestOff,
+	}
+}
+
+// Location returns the time zone dates should be shown in. It falls back to
+// UTC if the zone can't be loaded.
+func (p Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type PreferenceModelInterface interface {
+	Get(userID int) (Preferences, error)
+	Update(userID int, prefs Preferences) error
+}
+
+type PreferenceModel struct {
+	DB *sql.DB
+}
+
+// Get returns the user's preferences, or the defaults if they haven't saved
+// any.
+func (m *PreferenceModel) Get(userID int) (Preferences, error) {
+	var p Preferences
+
+	stmt := `SELECT timezone, default_expires, theme, page_size, digest FROM user_preferences WHERE user_id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&p.Timezone, &p.DefaultExpires, &p.Theme, &p.PageSize, &p.Digest)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DefaultPreferences(), nil
+		}
+		return Preferences{}, err
+	}
+
+	return p, nil
+}
+
+// Update saves the user's preferences, replacing any saved before.
+func (m *PreferenceModel) Update(userID int, prefs Preferences) error {
+	stmt := `INSERT INTO user_preferences (user_id, timezone, default_expires, theme, page_size, digest, updated)
+    VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE timezone = VALUES(timezone), default_expires = VALUES(default_expires),
+    theme = VALUES(theme), page_size = VALUES(page_size), digest = VALUES(digest), updated = VALUES(updated)`
+
+	_, err := m.DB.Exec(stmt, userID, prefs.Timezone, prefs.DefaultExpires, prefs.Theme, prefs.PageSize, prefs.Digest)
+	return err
+}
@@ -7,11 +7,26 @@ import (
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID       int
+	UserID   int
+	Title    string
+	Content  string
+	Created  time.Time
+	Expires  time.Time
+	Language string
+	// LanguageConfidence is set when Language was detected automatically
+	// rather than picked by the user.
+	LanguageConfidence float64
+}
+
+// SnippetEntry holds the values needed to create a snippet. Expires is the
+// number of days until the snippet expires.
+type SnippetEntry struct {
+	Title              string
+	Content            string
+	Expires            int
+	Language           string
+	LanguageConfidence float64
 }
 
 type SnippetModel struct {
@@ -19,17 +34,24 @@ type SnippetModel struct {
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(userID int, entry SnippetEntry) (int, error)
+	InsertMany(userID int, entries []SnippetEntry) ([]int, error)
 	Get(id int) (Snippet, error)
 	Latest() ([]Snippet, error)
+	ByUser(userID, limit, offset int) ([]Snippet, error)
+	Feed(userID, before, limit int) ([]Snippet, error)
+	Expiring(within time.Duration) ([]Snippet, error)
+	Search(query string, limit int) ([]Snippet, error)
+	Delete(id int) error
+	Renew(id, userID int, expires time.Time, days int) error
 }
 
 // insert
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+func (m *SnippetModel) Insert(userID int, entry SnippetEntry) (int, error) {
+	stmt := `INSERT INTO snippets (user_id, title, content, created, expires, language, language_confidence)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), NULLIF(?, ''), NULLIF(?, 0))`
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := m.DB.Exec(stmt, userID, entry.Title, entry.Content, entry.Expires, entry.Language, entry.LanguageConfidence)
 	if err != nil {
 		return 0, err
 	}
@@ -42,15 +64,66 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	return int(id), nil
 }
 
+// InsertMany creates all of the given snippets inside a single transaction and
+// returns their IDs in the same order. If any insert fails, none of the
+// snippets are kept.
+func (m *SnippetModel) InsertMany(userID int, entries []SnippetEntry) ([]int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, title, content, created, expires, language, language_confidence)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), NULLIF(?, ''), NULLIF(?, 0))`
+
+	insertStmt, err := tx.Prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer insertStmt.Close()
+
+	ids := make([]int, 0, len(entries))
+
+	for _, e := range entries {
+		result, err := insertStmt.Exec(userID, e.Title, e.Content, e.Expires, e.Language, e.LanguageConfidence)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, int(id))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Get returns a snippet which hasn't expired. Snippets hidden by a moderator
+// return ErrHidden.
 func (m *SnippetModel) Get(id int) (Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
+	stmt := `SELECT id, user_id, title, content, created, expires, language, language_confidence, hidden IS NOT NULL FROM snippets
     WHERE expires > UTC_TIMESTAMP() AND id = ?`
 
 	row := m.DB.QueryRow(stmt, id)
 
 	var s Snippet
+	var userID sql.NullInt64
+	var language sql.NullString
+	var confidence sql.NullFloat64
+	var hidden bool
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &userID, &s.Title, &s.Content, &s.Created, &s.Expires, &language, &confidence, &hidden)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -59,12 +132,22 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 			return Snippet{}, err
 		}
 	}
+
+	if hidden {
+		return Snippet{}, ErrHidden
+	}
+
+	// Snippets created before accounts existed have no owner.
+	s.UserID = int(userID.Int64)
+	s.Language = language.String
+	s.LanguageConfidence = confidence.Float64
+
 	return s, nil
 }
 
 func (m *SnippetModel) Latest() ([]Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT id, user_id, title, content, created, expires, language FROM snippets
+    WHERE expires > UTC_TIMESTAMP() AND hidden IS NULL ORDER BY id DESC LIMIT 10`
 
 	rows, err := m.DB.Query(stmt)
 
@@ -76,10 +159,125 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	var snippets []Snippet
 
+	for rows.Next() {
+		var s Snippet
+		var userID sql.NullInt64
+		var language sql.NullString
+
+		err = rows.Scan(&s.ID, &userID, &s.Title, &s.Content, &s.Created, &s.Expires, &language)
+		if err != nil {
+			return nil, err
+		}
+
+		s.UserID = int(userID.Int64)
+		s.Language = language.String
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// ByUser returns a page of the user's snippets which haven't expired or been
+// hidden, newest first.
+func (m *SnippetModel) ByUser(userID, limit, offset int) ([]Snippet, error) {
+	stmt := `SELECT id, user_id, title, content, created, expires, language FROM snippets
+    WHERE user_id = ? AND expires > UTC_TIMESTAMP() AND hidden IS NULL ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+		var userID sql.NullInt64
+		var language sql.NullString
+
+		err = rows.Scan(&s.ID, &userID, &s.Title, &s.Content, &s.Created, &s.Expires, &language)
+		if err != nil {
+			return nil, err
+		}
+
+		s.UserID = int(userID.Int64)
+		s.Language = language.String
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Feed returns a page of snippets by the people the user follows, which
+// haven't expired or been hidden, newest first. It pages by ID rather than
+// offset: only snippets with an ID below before are returned, and 0 starts
+// from the newest.
+func (m *SnippetModel) Feed(userID, before, limit int) ([]Snippet, error) {
+	stmt := `SELECT id, user_id, title, content, created, expires, language FROM snippets
+    WHERE user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)
+    AND expires > UTC_TIMESTAMP() AND hidden IS NULL AND (? = 0 OR id < ?)
+    ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userID, before, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+		var language sql.NullString
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &language)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Language = language.String
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Expiring returns the visible snippets with an author which expire within
+// the given time, soonest first. The content isn't loaded.
+func (m *SnippetModel) Expiring(within time.Duration) ([]Snippet, error) {
+	stmt := `SELECT id, user_id, title, created, expires FROM snippets
+    WHERE user_id IS NOT NULL AND hidden IS NULL
+    AND expires > UTC_TIMESTAMP() AND expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+    ORDER BY expires`
+
+	rows, err := m.DB.Query(stmt, int(within.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
 	for rows.Next() {
 		var s Snippet
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Created, &s.Expires)
 		if err != nil {
 			return nil, err
 		}
@@ -93,3 +291,67 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	return snippets, nil
 }
+
+// Search returns snippets whose title contains the query, newest first. Unlike
+// Latest it includes expired and hidden snippets, so it's only meant for
+// administrators.
+func (m *SnippetModel) Search(query string, limit int) ([]Snippet, error) {
+	stmt := `SELECT id, user_id, title, content, created, expires, language FROM snippets
+    WHERE title LIKE ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, "%"+escapeLike(query)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+		var userID sql.NullInt64
+		var language sql.NullString
+
+		err = rows.Scan(&s.ID, &userID, &s.Title, &s.Content, &s.Created, &s.Expires, &language)
+		if err != nil {
+			return nil, err
+		}
+
+		s.UserID = int(userID.Int64)
+		s.Language = language.String
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	result, err := m.DB.Exec("DELETE FROM snippets WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// Renew pushes the expiry of one of the user's snippets back by the given
+// number of days. It only succeeds while the snippet still expires at
+// expires, so a renew link can't be used twice. It returns ErrNoRecord
+// otherwise, or if the snippet has already expired or been hidden by a
+// moderator.
+func (m *SnippetModel) Renew(id, userID int, expires time.Time, days int) error {
+	stmt := `UPDATE snippets SET expires = DATE_ADD(expires, INTERVAL ? DAY)
+    WHERE id = ? AND user_id = ? AND expires = ? AND expires > UTC_TIMESTAMP() AND hidden IS NULL`
+
+	result, err := m.DB.Exec(stmt, days, id, userID, expires.UTC())
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
@@ -0,0 +1,40 @@
+package models
+
+import "database/sql"
+
+// Stats holds counts shown on the admin dashboard.
+type Stats struct {
+	Users          int
+	SuspendedUsers int
+	Snippets       int
+	ActiveSnippets int
+	HiddenSnippets int
+	OpenReports    int
+}
+
+type StatsModelInterface interface {
+	Get() (Stats, error)
+}
+
+type StatsModel struct {
+	DB *sql.DB
+}
+
+func (m *StatsModel) Get() (Stats, error) {
+	stmt := `SELECT
+    (SELECT COUNT(*) FROM users),
+    (SELECT COUNT(*) FROM users WHERE suspended IS NOT NULL),
+    (SELECT COUNT(*) FROM snippets),
+    (SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND hidden IS NULL),
+    (SELECT COUNT(*) FROM snippets WHERE hidden IS NOT NULL),
+    (SELECT COUNT(*) FROM reports WHERE status = 'open')`
+
+	var s Stats
+
+	err := m.DB.QueryRow(stmt).Scan(&s.Users, &s.SuspendedUsers, &s.Snippets, &s.ActiveSnippets, &s.HiddenSnippets, &s.OpenReports)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return s, nil
+}
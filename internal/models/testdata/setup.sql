@@ -1,9 +1,13 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NULL,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NOT NULL,
+    hidden DATETIME NULL,
+    language VARCHAR(30) NULL,
+    language_confidence FLOAT NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
@@ -11,16 +15,184 @@ CREATE INDEX idx_snippets_created ON snippets(created);
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
+    username VARCHAR(30) NULL,
+    bio VARCHAR(500) NOT NULL DEFAULT '',
     email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    hashed_password VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    suspended DATETIME NULL,
+    email_verified_at DATETIME NULL,
+    totp_secret VARCHAR(64) NULL,
+    totp_last_step BIGINT NULL,
+    delete_after DATETIME NULL,
+    delete_snippets BOOLEAN NOT NULL DEFAULT FALSE,
+    avatar_updated DATETIME NULL,
+    avatar_version INTEGER NOT NULL DEFAULT 0,
+    invite_id INTEGER NULL
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+ALTER TABLE users ADD CONSTRAINT users_uc_username UNIQUE (username);
+
+CREATE INDEX idx_users_delete_after ON users(delete_after);
+CREATE INDEX idx_users_invite_id ON users(invite_id);
+
+CREATE TABLE reports (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    reporter_id INTEGER NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    note TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created DATETIME NOT NULL,
+    resolved DATETIME NULL,
+    resolved_by INTEGER NULL
+);
+
+CREATE INDEX idx_reports_status ON reports(status);
+
+CREATE TABLE moderation_log (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    moderator_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    report_id INTEGER NULL,
+    snippet_id INTEGER NULL,
+    user_id INTEGER NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE password_resets (
+    token_hash BINARY(32) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+
+CREATE TABLE recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash BINARY(32) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+CREATE TABLE user_identities (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_identity UNIQUE (issuer, subject);
+
+CREATE TABLE avatars (
+    user_id INTEGER NOT NULL,
+    size SMALLINT NOT NULL,
+    image MEDIUMBLOB NOT NULL,
+    PRIMARY KEY (user_id, size)
+);
+
+CREATE TABLE access_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    scopes VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NULL,
+    last_used DATETIME NULL
+);
+
+ALTER TABLE access_tokens ADD CONSTRAINT access_tokens_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_access_tokens_user_id ON access_tokens(user_id);
+
+CREATE TABLE audit_events (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NULL,
+    event VARCHAR(30) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_user_id ON audit_events(user_id, id);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+    FOR EACH ROW IF NOT (NEW.id = OLD.id AND NEW.user_id <=> OLD.user_id AND NEW.event = OLD.event
+        AND NEW.created = OLD.created AND NEW.detail = '' AND NEW.ip = '' AND NEW.user_agent = '') THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+    END IF;
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TABLE invites (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    code VARCHAR(32) NOT NULL,
+    created_by INTEGER NOT NULL,
+    max_uses INTEGER NOT NULL,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires DATETIME NOT NULL,
+    revoked DATETIME NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE invites ADD CONSTRAINT invites_uc_code UNIQUE (code);
+CREATE INDEX idx_invites_created_by ON invites(created_by);
+
+CREATE TABLE login_links (
+    token_hash BINARY(32) NOT NULL PRIMARY KEY,
+    nonce_hash BINARY(32) NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_links_user_id ON login_links(user_id);
+
+CREATE TABLE user_preferences (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    timezone VARCHAR(64) NOT NULL,
+    default_expires INTEGER NOT NULL,
+    theme VARCHAR(10) NOT NULL,
+    page_size INTEGER NOT NULL,
+    digest VARCHAR(10) NOT NULL DEFAULT 'off',
+    digest_sent DATETIME NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE TABLE notifications (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    message VARCHAR(255) NOT NULL,
+    link VARCHAR(255) NOT NULL,
+    dedupe_key VARCHAR(100) NULL,
+    read_at DATETIME NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE notifications ADD CONSTRAINT notifications_uc_dedupe_key UNIQUE (user_id, dedupe_key);
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, id);
+
+CREATE TABLE follows (
+    follower_id INTEGER NOT NULL,
+    followee_id INTEGER NOT NULL,
+    followed DATETIME NOT NULL,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE INDEX idx_follows_followee_id ON follows(followee_id);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id, id);
 
 INSERT INTO users (name, email, hashed_password, created) VALUES (
     'Alice Jones',
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2022-01-01 09:18:24'
-);
\ No newline at end of file
+);
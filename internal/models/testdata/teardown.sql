@@ -1,3 +1,23 @@
+DROP TABLE follows;
+DROP TABLE notifications;
+DROP TABLE user_preferences;
+DROP TABLE login_links;
+DROP TABLE invites;
+DROP TABLE audit_events;
+DROP TABLE access_tokens;
+
+DROP TABLE avatars;
+
+DROP TABLE user_identities;
+
+DROP TABLE recovery_codes;
+
+DROP TABLE password_resets;
+
+DROP TABLE moderation_log;
+
+DROP TABLE reports;
+
 DROP TABLE users;
 
 DROP TABLE snippets;
\ No newline at end of file
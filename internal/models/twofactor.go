@@ -0,0 +1,169 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued when two-factor
+// authentication is turned on.
+const RecoveryCodeCount = 10
+
+type TwoFactorModelInterface interface {
+	Secret(userID int) (string, error)
+	Enable(userID int, secret string) ([]string, error)
+	Disable(userID int) error
+	UseStep(userID int, step int64) error
+	UseRecoveryCode(userID int, code string) error
+	RecoveryCodesLeft(userID int) (int, error)
+}
+
+// TwoFactorModel stores each user's TOTP secret and their recovery codes.
+// Recovery codes are only stored as SHA-256 hashes.
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+// normalizeRecoveryCode lets users type recovery codes in any case, with or
+// without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) []byte {
+	return hashToken(normalizeRecoveryCode(code))
+}
+
+// newRecoveryCode returns a random code like "ABCDE-FGHIJ".
+func newRecoveryCode() string {
+	text := rand.Text()
+	return text[:5] + "-" + text[5:10]
+}
+
+// Secret returns the user's TOTP secret, or ErrNoRecord if they haven't
+// turned on two-factor authentication.
+func (m *TwoFactorModel) Secret(userID int) (string, error) {
+	var secret sql.NullString
+
+	err := m.DB.QueryRow("SELECT totp_secret FROM users WHERE id = ?", userID).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	if !secret.Valid {
+		return "", ErrNoRecord
+	}
+
+	return secret.String, nil
+}
+
+// Enable turns on two-factor authentication for the user and returns a new
+// set of recovery codes, replacing any old ones. The plaintext codes are
+// only available here.
+func (m *TwoFactorModel) Enable(userID int, secret string) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE users SET totp_secret = ?, totp_last_step = NULL WHERE id = ?", secret, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkAffected(result); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	insertStmt, err := tx.Prepare("INSERT INTO recovery_codes (user_id, code_hash, created) VALUES(?, ?, UTC_TIMESTAMP())")
+	if err != nil {
+		return nil, err
+	}
+
+	defer insertStmt.Close()
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+
+		_, err = insertStmt.Exec(userID, hashRecoveryCode(codes[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication and deletes the user's
+// recovery codes.
+func (m *TwoFactorModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = NULL, totp_last_step = NULL WHERE id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records that a code from the given time step has been used. It
+// returns ErrNoRecord if a code from the same or a later step was used
+// before, so an intercepted code can't be replayed.
+func (m *TwoFactorModel) UseStep(userID int, step int64) error {
+	stmt := `UPDATE users SET totp_last_step = ?
+    WHERE id = ? AND totp_secret IS NOT NULL AND (totp_last_step IS NULL OR totp_last_step < ?)`
+
+	result, err := m.DB.Exec(stmt, step, userID, step)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// UseRecoveryCode deletes the matching recovery code, or returns ErrNoRecord
+// if the user has no such code.
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	result, err := m.DB.Exec("DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?", userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// RecoveryCodesLeft returns how many unused recovery codes the user has.
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+	err := m.DB.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?", userID).Scan(&n)
+	return n, err
+}
@@ -8,58 +8,217 @@ import (
 	"time"
 
 	"github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles a user can have. Every account starts out as RoleUser.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
 )
 
 type User struct {
-	ID             int
-	Name           string
+	ID   int
+	Name string
+	// Username is the user's unique handle, used in the URL of their profile.
+	// Accounts created before usernames existed may not have one yet.
+	Username string
+	Bio      string
+	// HasAvatar is set when the user has uploaded a profile picture.
+	// AvatarVersion changes whenever they upload or remove one.
+	HasAvatar      bool
+	AvatarVersion  int
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Role           string
+	Suspended      bool
+	EmailVerified  bool
+	// TwoFactorEnabled is set when the user has turned on TOTP two-factor
+	// authentication.
+	TwoFactorEnabled bool
+	// DeleteAfter is when the account is due to be deleted. It's the zero
+	// time unless the user has asked for their account to be deleted.
+	DeleteAfter time.Time
+}
+
+// IsModerator reports whether the user can act on abuse reports.
+// Administrators are moderators too.
+func (u User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
+// IsAdmin reports whether the user can access the admin area.
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, username, email, password string) (int, error)
+	InsertWithInvite(name, username, email, password, inviteCode string) (int, error)
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
 	Get(id int) (User, error)
+	GetByEmail(email string) (User, error)
+	GetByUsername(username string) (User, error)
+	UpdateProfile(id int, username, bio string) error
 	PasswordUpdate(id int, currentPassword, newPassword string) error
+	Search(query string, limit int) ([]User, error)
+	SetSuspended(id int, suspended bool) error
+	VerifyEmail(id int, email string) error
+	ChangeEmail(id int, oldEmail, newEmail string) error
+	ScheduleDeletion(id int, password string, after time.Duration, deleteSnippets bool) error
+	CancelDeletion(id int) (bool, error)
+	DeleteScheduled() ([]int, error)
+}
+
+// PasswordHasher hashes passwords for storage. Verify reports whether the
+// stored hash should be replaced, because it was made with an algorithm or
+// parameters which are no longer used for new hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (match, needsRehash bool, err error)
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Hasher PasswordHasher
+}
+
+// userColumns are the columns read by scanUser.
+const userColumns = `id, name, COALESCE(username, ''), bio, email, created, role, suspended IS NOT NULL, email_verified_at IS NOT NULL,
+    totp_secret IS NOT NULL, delete_after, avatar_updated IS NOT NULL, avatar_version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	var deleteAfter sql.NullTime
+
+	err := row.Scan(&user.ID, &user.Name, &user.Username, &user.Bio, &user.Email, &user.Created, &user.Role, &user.Suspended, &user.EmailVerified, &user.TwoFactorEnabled, &deleteAfter, &user.HasAvatar, &user.AvatarVersion)
+	if err != nil {
+		return User{}, err
+	}
+
+	user.DeleteAfter = deleteAfter.Time
+
+	return user, nil
+}
+
+// isDuplicate reports whether err is a MySQL duplicate entry error for the
+// given unique constraint.
+func isDuplicate(err error, constraint string) bool {
+	var mySQLError *mysql.MySQLError
+	return errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+}
+
+// checkPassword returns ErrInvalidCredentials if the password doesn't match
+// the hash. Otherwise it reports whether the hash needs to be replaced.
+func (m *UserModel) checkPassword(hashedPassword, password string) (bool, error) {
+	match, needsRehash, err := m.Hasher.Verify(hashedPassword, password)
+	if err != nil {
+		return false, err
+	}
+
+	if !match {
+		return false, ErrInvalidCredentials
+	}
+
+	return needsRehash, nil
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+func (m *UserModel) Insert(name, username, email, password string) (int, error) {
+	hashPassword, err := m.Hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	return insertUser(m.DB, name, username, email, hashPassword, sql.NullInt64{})
+}
+
+// InsertWithInvite creates a user who signed up with an invite code, and
+// uses up one of the invite's uses. It returns ErrInvalidInvite if the
+// invite doesn't exist, has been revoked, used up or has expired.
+func (m *UserModel) InsertWithInvite(name, username, email, password, inviteCode string) (int, error) {
+	hashPassword, err := m.Hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return nil
+		return 0, err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-    VALUES(?, ?, ?, UTC_TIMESTAMP())`
-	_, err = m.DB.Exec(stmt, name, email, string(hashPassword))
+	defer tx.Rollback()
+
+	var inviteID int64
+
+	stmt := `SELECT id FROM invites
+    WHERE code = ? AND revoked IS NULL AND uses < max_uses AND expires > UTC_TIMESTAMP() FOR UPDATE`
 
+	err = tx.QueryRow(stmt, inviteCode).Scan(&inviteID)
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidInvite
 		}
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := insertUser(tx, name, username, email, hashPassword, sql.NullInt64{Int64: inviteID, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE invites SET uses = uses + 1 WHERE id = ?", inviteID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertUser(db execer, name, username, email, hashedPassword string, inviteID sql.NullInt64) (int, error) {
+	stmt := `INSERT INTO users (name, username, email, hashed_password, invite_id, created)
+    VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := db.Exec(stmt, name, username, email, hashedPassword, inviteID)
+
+	if err != nil {
+		if isDuplicate(err, "users_uc_email") {
+			return 0, ErrDuplicateEmail
+		}
+		if isDuplicate(err, "users_uc_username") {
+			return 0, ErrDuplicateUsername
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
-	var hashedPassword []byte
+	var hashedPassword string
+	var suspended bool
 
-	stmt := "select id, hashed_password from users where email=?"
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	stmt := "select id, hashed_password, suspended IS NOT NULL from users where email=?"
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &suspended)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -68,11 +227,22 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	needsRehash, err := m.checkPassword(hashedPassword, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
-		} else {
+		return 0, err
+	}
+
+	// Only tell the user about the suspension once they've proven they own
+	// the account.
+	if suspended {
+		return 0, ErrSuspended
+	}
+
+	// This is the only time the plaintext password is available, so take
+	// the chance to upgrade hashes made with an outdated algorithm or cost.
+	if needsRehash {
+		err = m.rehash(id, hashedPassword, password)
+		if err != nil {
 			return 0, err
 		}
 	}
@@ -80,6 +250,19 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+// rehash replaces the user's password hash with a new one made by the
+// current hasher, unless the password was changed in the meantime.
+func (m *UserModel) rehash(id int, oldHash, password string) error {
+	newHash, err := m.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = ? WHERE id = ? AND hashed_password = ?"
+	_, err = m.DB.Exec(stmt, newHash, id, oldHash)
+	return err
+}
+
 func (m *UserModel) Exists(id int) (bool, error) {
 
 	var exists bool
@@ -92,11 +275,9 @@ func (m *UserModel) Exists(id int) (bool, error) {
 }
 
 func (m *UserModel) Get(id int) (User, error) {
-	var user User
+	stmt := "SELECT " + userColumns + " FROM users WHERE id = ?"
 
-	stmt := "SELECT id, name, email, created  FROM users WHERE id = ?;"
-
-	err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
+	user, err := scanUser(m.DB.QueryRow(stmt, id))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -108,8 +289,52 @@ func (m *UserModel) Get(id int) (User, error) {
 	return user, nil
 }
 
+// GetByEmail returns the user with the given email address, or ErrNoRecord.
+func (m *UserModel) GetByEmail(email string) (User, error) {
+	stmt := "SELECT " + userColumns + " FROM users WHERE email = ?"
+
+	user, err := scanUser(m.DB.QueryRow(stmt, email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// GetByUsername returns the user with the given username, or ErrNoRecord.
+func (m *UserModel) GetByUsername(username string) (User, error) {
+	stmt := "SELECT " + userColumns + " FROM users WHERE username = ?"
+
+	user, err := scanUser(m.DB.QueryRow(stmt, username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// UpdateProfile changes the user's username and bio. It returns
+// ErrDuplicateUsername if someone else already has the username.
+func (m *UserModel) UpdateProfile(id int, username, bio string) error {
+	_, err := m.DB.Exec("UPDATE users SET username = ?, bio = ? WHERE id = ?", username, bio, id)
+	if err != nil {
+		if isDuplicate(err, "users_uc_username") {
+			return ErrDuplicateUsername
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
-	var hashedPassword []byte
+	var hashedPassword string
 
 	stmt := "select hashed_password from users where id=?"
 	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
@@ -122,26 +347,267 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(currentPassword))
+	_, err = m.checkPassword(hashedPassword, currentPassword)
+	if err != nil {
+		return err
+	}
 
+	newHashedPassword, err := m.Hasher.Hash(newPassword)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return ErrInvalidCredentials
-		} else {
-			return err
+		return err
+	}
+
+	stmt = "UPDATE users SET hashed_password = ? WHERE id = ?"
+	_, err = m.DB.Exec(stmt, newHashedPassword, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Search returns users whose name or email contains the query, newest first.
+// An empty query matches every user.
+func (m *UserModel) Search(query string, limit int) ([]User, error) {
+	stmt := "SELECT " + userColumns + ` FROM users
+    WHERE name LIKE ? OR email LIKE ? ORDER BY id DESC LIMIT ?`
+
+	pattern := "%" + escapeLike(query) + "%"
+
+	rows, err := m.DB.Query(stmt, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
 		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	return users, nil
+}
+
+// SetSuspended suspends or reinstates a user. Suspended users can't log in.
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	stmt := "UPDATE users SET suspended = NULL WHERE id = ?"
+	if suspended {
+		stmt = "UPDATE users SET suspended = COALESCE(suspended, UTC_TIMESTAMP()) WHERE id = ?"
+	}
+
+	result, err := m.DB.Exec(stmt, id)
 	if err != nil {
 		return err
 	}
 
-	stmt = "UPDATE users SET hashed_password = ? WHERE id = ?"
-	_, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+	return checkAffected(result)
+}
+
+// VerifyEmail marks the user's email address as verified. The address must
+// still be the one the verification was sent to; otherwise ErrNoRecord is
+// returned.
+func (m *UserModel) VerifyEmail(id int, email string) error {
+	stmt := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, UTC_TIMESTAMP())
+    WHERE id = ? AND email = ?`
+
+	result, err := m.DB.Exec(stmt, id, email)
 	if err != nil {
 		return err
 	}
 
+	// RowsAffected is 0 both when nothing matched and when the address was
+	// already verified, so check which one it was.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		var exists bool
+		stmt = "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND email = ?)"
+		err = m.DB.QueryRow(stmt, id, email).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
 	return nil
 }
+
+// ChangeEmail swaps the user's email address for one they've just proven
+// they own, so it's marked as verified too. The current address must still
+// be oldEmail; otherwise ErrNoRecord is returned. If another account has
+// taken the new address in the meantime, ErrDuplicateEmail is returned.
+func (m *UserModel) ChangeEmail(id int, oldEmail, newEmail string) error {
+	stmt := `UPDATE users SET email = ?, email_verified_at = UTC_TIMESTAMP()
+    WHERE id = ? AND email = ?`
+
+	result, err := m.DB.Exec(stmt, newEmail, id, oldEmail)
+	if err != nil {
+		if isDuplicate(err, "users_uc_email") {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// ScheduleDeletion asks for the user's account to be deleted once the grace
+// period given by after has passed. The user has to confirm their password.
+// If deleteSnippets is false their snippets are kept but no longer belong to
+// anyone, like the snippets created before accounts owned snippets.
+func (m *UserModel) ScheduleDeletion(id int, password string, after time.Duration, deleteSnippets bool) error {
+	var hashedPassword string
+
+	err := m.DB.QueryRow("SELECT hashed_password FROM users WHERE id = ?", id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	_, err = m.checkPassword(hashedPassword, password)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET delete_after = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), delete_snippets = ?
+    WHERE id = ?`
+
+	_, err = m.DB.Exec(stmt, int(after.Seconds()), deleteSnippets, id)
+	return err
+}
+
+// CancelDeletion cancels a scheduled deletion. It reports whether one was
+// scheduled.
+func (m *UserModel) CancelDeletion(id int) (bool, error) {
+	stmt := "UPDATE users SET delete_after = NULL, delete_snippets = FALSE WHERE id = ? AND delete_after IS NOT NULL"
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// DeleteScheduled deletes every account whose grace period has passed, along
+// with everything else stored about the user, and returns their IDs.
+func (m *UserModel) DeleteScheduled() ([]int, error) {
+	rows, err := m.DB.Query("SELECT id FROM users WHERE delete_after <= UTC_TIMESTAMP()")
+	if err != nil {
+		return nil, err
+	}
+
+	var due []int
+
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, id)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var deleted []int
+
+	for _, id := range due {
+		err = m.deleteUser(id)
+		if errors.Is(err, ErrNoRecord) {
+			continue
+		} else if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, id)
+	}
+
+	return deleted, nil
+}
+
+// deleteUser deletes a single account whose grace period has passed. It
+// returns ErrNoRecord if the deletion was cancelled in the meantime.
+func (m *UserModel) deleteUser(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var deleteSnippets bool
+
+	stmt := "SELECT delete_snippets FROM users WHERE id = ? AND delete_after <= UTC_TIMESTAMP() FOR UPDATE"
+
+	err = tx.QueryRow(stmt, id).Scan(&deleteSnippets)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	stmts := []string{
+		"DELETE FROM password_resets WHERE user_id = ?",
+		"DELETE FROM login_links WHERE user_id = ?",
+		"DELETE FROM user_preferences WHERE user_id = ?",
+		"DELETE FROM notifications WHERE user_id = ?",
+		"DELETE FROM follows WHERE follower_id = ?",
+		"DELETE FROM follows WHERE followee_id = ?",
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+		"DELETE FROM user_identities WHERE user_id = ?",
+		"DELETE FROM avatars WHERE user_id = ?",
+		"DELETE FROM access_tokens WHERE user_id = ?",
+		// Audit events are kept, but without the personal data in them.
+		"UPDATE audit_events SET detail = '', ip = '', user_agent = '' WHERE user_id = ?",
+		// Invites are kept so it's still known who signed up with them.
+		"UPDATE invites SET revoked = UTC_TIMESTAMP() WHERE created_by = ? AND revoked IS NULL",
+	}
+
+	if deleteSnippets {
+		stmts = append(stmts,
+			"DELETE FROM reports WHERE snippet_id IN (SELECT id FROM snippets WHERE user_id = ?)",
+			"DELETE FROM snippets WHERE user_id = ?",
+		)
+	} else {
+		stmts = append(stmts, "UPDATE snippets SET user_id = NULL WHERE user_id = ?")
+	}
+
+	stmts = append(stmts, "DELETE FROM users WHERE id = ?")
+
+	for _, stmt := range stmts {
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
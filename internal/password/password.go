@@ -0,0 +1,170 @@
+// Package password hashes passwords for storage. Hashes are stored in the
+// PHC string format, e.g. "$argon2id$v=19$m=65536,t=3,p=2$salt$hash", so
+// each one records the algorithm and parameters it was made with. bcrypt
+// hashes keep their usual "$2a$12$..." form, which the PHC format adopts.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms new passwords can be hashed with.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var Algorithms = []string{Argon2id, Bcrypt}
+
+// ErrUnknownFormat is returned for a stored hash which wasn't made by any
+// supported algorithm.
+var ErrUnknownFormat = errors.New("password: unknown hash format")
+
+// Argon2idParams are the cost parameters for Argon2id.
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the recommendations of RFC 9106 for systems
+// with limited memory.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes new passwords with Algorithm, and verifies passwords against
+// hashes made with any supported algorithm.
+type Hasher struct {
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+}
+
+// NewHasher returns a Hasher which uses Argon2id with the default
+// parameters.
+func NewHasher() *Hasher {
+	return &Hasher{
+		Algorithm:  Argon2id,
+		Argon2id:   DefaultArgon2idParams,
+		BcryptCost: 12,
+	}
+}
+
+// Hash returns the encoded hash of the password.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case Argon2id:
+		salt := make([]byte, h.Argon2id.SaltLength)
+		rand.Read(salt)
+		return encodeArgon2id(h.Argon2id, salt, password), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("password: unsupported algorithm %q", h.Algorithm)
+	}
+}
+
+// Verify reports whether the password matches the encoded hash. If it does,
+// needsRehash is true when the hash was made with a different algorithm or
+// weaker parameters than the Hasher uses now, so the caller can replace it
+// while it has the plaintext password.
+func (h *Hasher) Verify(encoded, password string) (match, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+
+		return true, h.Algorithm != Argon2id || params != h.Argon2id, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, err
+		}
+
+		return true, h.Algorithm != Bcrypt || cost != h.BcryptCost, nil
+
+	default:
+		return false, false, ErrUnknownFormat
+	}
+}
+
+var b64 = base64.RawStdEncoding
+
+func encodeArgon2id(p Argon2idParams, salt []byte, password string) string {
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 6 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(fields[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	_, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err := b64.DecodeString(fields[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	key, err := b64.DecodeString(fields[5])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,107 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testHasher uses cheap parameters so the tests run quickly.
+func testHasher(algorithm string) *Hasher {
+	return &Hasher{
+		Algorithm:  algorithm,
+		Argon2id:   Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		BcryptCost: bcrypt.MinCost,
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			h := testHasher(algorithm)
+
+			hash, err := h.Hash("pa$$word")
+			assert.NilError(t, err)
+
+			match, needsRehash, err := h.Verify(hash, "pa$$word")
+			assert.NilError(t, err)
+			assert.Equal(t, match, true)
+			assert.Equal(t, needsRehash, false)
+
+			match, _, err = h.Verify(hash, "wrong")
+			assert.NilError(t, err)
+			assert.Equal(t, match, false)
+		})
+	}
+}
+
+func TestArgon2idFormat(t *testing.T) {
+	hash, err := testHasher(Argon2id).Hash("pa$$word")
+	assert.NilError(t, err)
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash %q", hash)
+	}
+
+	// Two hashes of the same password use different salts.
+	other, err := testHasher(Argon2id).Hash("pa$$word")
+	assert.NilError(t, err)
+	if hash == other {
+		t.Errorf("got the same hash twice")
+	}
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	bcryptHash, err := testHasher(Bcrypt).Hash("pa$$word")
+	assert.NilError(t, err)
+
+	argon2Hash, err := testHasher(Argon2id).Hash("pa$$word")
+	assert.NilError(t, err)
+
+	stronger := testHasher(Argon2id)
+	stronger.Argon2id.Iterations = 2
+
+	costlier := testHasher(Bcrypt)
+	costlier.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   string
+		want   bool
+	}{
+		{name: "bcrypt to Argon2id", hasher: testHasher(Argon2id), hash: bcryptHash, want: true},
+		{name: "Argon2id to bcrypt", hasher: testHasher(Bcrypt), hash: argon2Hash, want: true},
+		{name: "Argon2id parameters changed", hasher: stronger, hash: argon2Hash, want: true},
+		{name: "bcrypt cost changed", hasher: costlier, hash: bcryptHash, want: true},
+		{name: "Up to date", hasher: testHasher(Argon2id), hash: argon2Hash, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := tt.hasher.Verify(tt.hash, "pa$$word")
+			assert.NilError(t, err)
+			assert.Equal(t, match, true)
+			assert.Equal(t, needsRehash, tt.want)
+		})
+	}
+}
+
+func TestVerifyExistingBcryptHash(t *testing.T) {
+	// A hash of "pa$$word" from before Argon2id was supported.
+	const hash = "$2a$12$aDUETDbOetAAc0Vk.2TdCuhxmCDkOEBR11ifp0bS3suZE2MFvy6OW"
+
+	match, needsRehash, err := NewHasher().Verify(hash, "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+	assert.Equal(t, needsRehash, true)
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=1024$broken", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA"} {
+		_, _, err := NewHasher().Verify(hash, "pa$$word")
+		assert.Equal(t, err, ErrUnknownFormat)
+	}
+}
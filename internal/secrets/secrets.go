@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Finding describes a possible secret found in a piece of text. It
+// intentionally doesn't hold the matched text so that findings can be logged
+// safely.
+type Finding struct {
+	Detector string
+	Line     int
+}
+
+// Detector is implemented by anything which can look for secrets in text.
+// New kinds of checks can be added to a Scanner without changing the scanner
+// itself.
+type Detector interface {
+	Name() string
+	Detect(content string) []Finding
+}
+
+// Scanner runs content through a set of detectors.
+type Scanner struct {
+	Detectors []Detector
+}
+
+// NewScanner returns a Scanner which uses the given detectors.
+func NewScanner(detectors ...Detector) *Scanner {
+	return &Scanner{Detectors: detectors}
+}
+
+// Scan returns the findings of every detector, in detector order.
+func (s *Scanner) Scan(content string) []Finding {
+	var findings []Finding
+	for _, d := range s.Detectors {
+		findings = append(findings, d.Detect(content)...)
+	}
+	return findings
+}
+
+// DefaultDetectors returns the detectors used by the application: regex rules
+// for common token formats, PEM private key headers and a high-entropy
+// heuristic.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		&RegexDetector{Label: "AWS access key ID", RX: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		&RegexDetector{Label: "AWS secret access key", RX: regexp.MustCompile(`(?i)aws_?secret_?access_?key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+		&RegexDetector{Label: "GitHub token", RX: regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b`)},
+		&RegexDetector{Label: "Slack token", RX: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+		&RegexDetector{Label: "Google API key", RX: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+		&RegexDetector{Label: "Stripe secret key", RX: regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+		&RegexDetector{Label: "Private key", RX: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)},
+		&EntropyDetector{MinLength: 32, Threshold: 4.5},
+	}
+}
+
+// RegexDetector reports every line which matches RX.
+type RegexDetector struct {
+	Label string
+	RX    *regexp.Regexp
+}
+
+func (d *RegexDetector) Name() string {
+	return d.Label
+}
+
+func (d *RegexDetector) Detect(content string) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(content, "\n") {
+		if d.RX.MatchString(line) {
+			findings = append(findings, Finding{Detector: d.Label, Line: i + 1})
+		}
+	}
+	return findings
+}
+
+// EntropyDetector reports lines containing a long token made of base64 or
+// URL-safe characters whose Shannon entropy is at least Threshold bits per
+// character. Random keys score high, while words and identifiers don't.
+type EntropyDetector struct {
+	MinLength int
+	Threshold float64
+}
+
+var tokenRX = regexp.MustCompile(`[A-Za-z0-9+/=_-]+`)
+
+func (d *EntropyDetector) Name() string {
+	return "High-entropy string"
+}
+
+func (d *EntropyDetector) Detect(content string) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(content, "\n") {
+		for _, token := range tokenRX.FindAllString(line, -1) {
+			if len(token) >= d.MinLength && hasLettersAndDigits(token) && entropy(token) >= d.Threshold {
+				findings = append(findings, Finding{Detector: d.Name(), Line: i + 1})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	n := float64(len(s))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+func hasLettersAndDigits(s string) bool {
+	return strings.ContainsAny(s, "0123456789") &&
+		strings.ContainsAny(strings.ToLower(s), "abcdefghijklmnopqrstuvwxyz")
+}
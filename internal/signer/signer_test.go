@@ -0,0 +1,81 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func TestSigner(t *testing.T) {
+	s := New([]byte("test-key"))
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	token := s.Sign("verify-email", "1:alice@example.com", now.Add(time.Hour))
+
+	tests := []struct {
+		name        string
+		signer      *Signer
+		purpose     string
+		token       string
+		now         time.Time
+		wantPayload string
+		wantErr     error
+	}{
+		{
+			name:        "Valid",
+			signer:      s,
+			purpose:     "verify-email",
+			token:       token,
+			now:         now,
+			wantPayload: "1:alice@example.com",
+		},
+		{
+			name:    "Expired",
+			signer:  s,
+			purpose: "verify-email",
+			token:   token,
+			now:     now.Add(2 * time.Hour),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "Wrong purpose",
+			signer:  s,
+			purpose: "reset-password",
+			token:   token,
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Wrong key",
+			signer:  New([]byte("other-key")),
+			purpose: "verify-email",
+			token:   token,
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Tampered payload",
+			signer:  s,
+			purpose: "verify-email",
+			token:   "Mjph" + token[4:],
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Garbage",
+			signer:  s,
+			purpose: "verify-email",
+			token:   "not-a-token",
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.signer.Verify(tt.purpose, tt.token, tt.now)
+			assert.Equal(t, payload, tt.wantPayload)
+			assert.Equal(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package throttle slows down and locks out repeated failed attempts, such
+// as wrong passwords, using an in-memory sliding window per key.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy controls how a Limiter reacts to failures. Only failures within the
+// last Window count.
+type Policy struct {
+	Window time.Duration
+	// FreeAttempts is the number of failures allowed without any delay.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure beyond FreeAttempts. It
+	// doubles with each further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures which lock the key for
+	// LockoutDuration. Zero turns lockouts off.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+// Result describes whether an attempt is allowed right now.
+type Result struct {
+	// Wait is how long until the next attempt is allowed. It's zero if an
+	// attempt can be made now.
+	Wait time.Duration
+	// Locked is set while the key is locked out.
+	Locked bool
+	// Unlocked is set when an expired lockout was cleared by this check.
+	Unlocked bool
+}
+
+// Allowed reports whether an attempt can be made now.
+func (r Result) Allowed() bool {
+	return r.Wait == 0 && !r.Locked
+}
+
+type entry struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+// Limiter tracks failures per key. It's safe for concurrent use.
+type Limiter struct {
+	policy  Policy
+	mu      sync.Mutex
+	entries map[string]*entry
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func New(policy Policy) *Limiter {
+	return &Limiter{
+		policy:  policy,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// prune drops failures which have left the window. It must be called with
+// the lock held.
+func (l *Limiter) prune(e *entry, now time.Time) {
+	cutoff := now.Add(-l.policy.Window)
+
+	i := 0
+	for i < len(e.failures) && !e.failures[i].After(cutoff) {
+		i++
+	}
+	e.failures = e.failures[i:]
+}
+
+// delay returns the backoff after n failures.
+func (l *Limiter) delay(n int) time.Duration {
+	if n < l.policy.FreeAttempts || l.policy.BaseDelay == 0 {
+		return 0
+	}
+
+	d := l.policy.BaseDelay
+	for i := l.policy.FreeAttempts; i < n; i++ {
+		d *= 2
+		if d >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+
+	return min(d, l.policy.MaxDelay)
+}
+
+// Check reports whether an attempt for key is allowed now.
+func (l *Limiter) Check(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return Result{}
+	}
+
+	now := l.now()
+	var result Result
+
+	if !e.lockedUntil.IsZero() {
+		if now.Before(e.lockedUntil) {
+			return Result{Wait: e.lockedUntil.Sub(now), Locked: true}
+		}
+		e.lockedUntil = time.Time{}
+		result.Unlocked = true
+	}
+
+	l.prune(e, now)
+
+	if len(e.failures) == 0 {
+		delete(l.entries, key)
+		return result
+	}
+
+	last := e.failures[len(e.failures)-1]
+	if next := last.Add(l.delay(len(e.failures))); now.Before(next) {
+		result.Wait = next.Sub(now)
+	}
+
+	return result
+}
+
+// Fail records a failed attempt for key. It returns true if this failure
+// locked the key.
+func (l *Limiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	e, ok := l.entries[key]
+	if !ok {
+		if len(l.entries) >= sweepSize {
+			l.sweep(now)
+		}
+		e = &entry{}
+		l.entries[key] = e
+	}
+
+	l.prune(e, now)
+	e.failures = append(e.failures, now)
+
+	if l.policy.LockoutThreshold > 0 && len(e.failures) >= l.policy.LockoutThreshold {
+		e.lockedUntil = now.Add(l.policy.LockoutDuration)
+		e.failures = nil
+		return true
+	}
+
+	return false
+}
+
+// sweepSize is the number of keys at which stale ones are swept away.
+const sweepSize = 1024
+
+// sweep forgets keys which have nothing left to remember, so the map doesn't
+// grow without bound. It must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, e := range l.entries {
+		l.prune(e, now)
+		if len(e.failures) == 0 && !now.Before(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// Reset forgets everything about key, including a lockout. It returns true
+// if key was locked.
+func (l *Limiter) Reset(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return false
+	}
+
+	delete(l.entries, key)
+	return l.now().Before(e.lockedUntil)
+}
+
+// LockedUntil returns when the lockout on key ends, or the zero time if key
+// isn't locked.
+func (l *Limiter) LockedUntil(key string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || !l.now().Before(e.lockedUntil) {
+		return time.Time{}
+	}
+
+	return e.lockedUntil
+}
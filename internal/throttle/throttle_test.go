@@ -0,0 +1,124 @@
+package throttle
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+// clock is a fake time source which only moves when told to.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(p Policy) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(p)
+	l.now = c.now
+	return l, c
+}
+
+var testPolicy = Policy{
+	Window:           15 * time.Minute,
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         4 * time.Second,
+	LockoutThreshold: 6,
+	LockoutDuration:  10 * time.Minute,
+}
+
+func TestBackoff(t *testing.T) {
+	l, c := newTestLimiter(testPolicy)
+
+	assert.Equal(t, l.Check("alice").Allowed(), true)
+
+	l.Fail("alice")
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, time.Second)
+
+	// Other keys aren't affected.
+	assert.Equal(t, l.Check("bob").Allowed(), true)
+
+	c.advance(time.Second)
+	assert.Equal(t, l.Check("alice").Allowed(), true)
+
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, 2*time.Second)
+
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, 4*time.Second)
+
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, 4*time.Second)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l, c := newTestLimiter(testPolicy)
+
+	l.Fail("alice")
+	c.advance(10 * time.Minute)
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, time.Second)
+
+	// The first failure drops out of the window.
+	c.advance(6 * time.Minute)
+	assert.Equal(t, l.Check("alice").Allowed(), true)
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Wait, time.Second)
+}
+
+func TestLockout(t *testing.T) {
+	l, c := newTestLimiter(testPolicy)
+
+	for i := 1; i < testPolicy.LockoutThreshold; i++ {
+		assert.Equal(t, l.Fail("alice"), false)
+	}
+	assert.Equal(t, l.Fail("alice"), true)
+
+	r := l.Check("alice")
+	assert.Equal(t, r.Locked, true)
+	assert.Equal(t, r.Wait, 10*time.Minute)
+	assert.Equal(t, l.LockedUntil("alice"), c.t.Add(10*time.Minute))
+
+	c.advance(10 * time.Minute)
+	r = l.Check("alice")
+	assert.Equal(t, r.Allowed(), true)
+	assert.Equal(t, r.Unlocked, true)
+
+	// The lockout starts the count again.
+	assert.Equal(t, l.Check("alice").Unlocked, false)
+	l.Fail("alice")
+	assert.Equal(t, l.Check("alice").Allowed(), true)
+}
+
+func TestReset(t *testing.T) {
+	l, _ := newTestLimiter(testPolicy)
+
+	for range testPolicy.LockoutThreshold {
+		l.Fail("alice")
+	}
+
+	assert.Equal(t, l.Reset("alice"), true)
+	assert.Equal(t, l.Check("alice").Allowed(), true)
+	assert.Equal(t, l.LockedUntil("alice").IsZero(), true)
+	assert.Equal(t, l.Reset("alice"), false)
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newTestLimiter(testPolicy)
+
+	for i := range sweepSize {
+		l.Fail(strconv.Itoa(i))
+	}
+
+	c.advance(time.Hour)
+	l.Fail("alice")
+
+	assert.Equal(t, len(l.entries), 1)
+}
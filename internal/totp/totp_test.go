@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+// The SHA-1 test vectors from RFC 6238 appendix B, truncated to six digits.
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		assert.NilError(t, err)
+		assert.Equal(t, code, tt.want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := GenerateSecret()
+	now := time.Unix(1700000000, 0)
+
+	code, err := Code(secret, Step(now))
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name   string
+		code   string
+		at     time.Time
+		wantOK bool
+	}{
+		{"Current period", code, now, true},
+		{"With spaces", code[:3] + " " + code[3:], now, true},
+		{"Previous period", code, now.Add(Period), true},
+		{"Too old", code, now.Add(2 * Period), false},
+		{"Wrong code", "000000", now, code == "000000"},
+		{"Too short", code[:5], now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(secret, tt.code, tt.at)
+			assert.Equal(t, ok, tt.wantOK)
+			if ok {
+				assert.Equal(t, step, Step(now))
+			}
+		})
+	}
+
+	_, ok := Validate("not base32!", code, now)
+	assert.Equal(t, ok, false)
+}
+
+func TestURL(t *testing.T) {
+	u := URL("Snippetbox", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(u, "otpauth://totp/Snippetbox:alice@example.com?") {
+		t.Errorf("unexpected URL %q", u)
+	}
+	assert.StringContains(t, u, "secret=JBSWY3DPEHPK3PXP")
+}
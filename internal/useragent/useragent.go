@@ -0,0 +1,75 @@
+// Package useragent turns User-Agent headers into short descriptions like
+// "Firefox on Linux", good enough for users to recognise their devices.
+package useragent
+
+import "strings"
+
+// Agent is the browser and operating system a request came from. Either can
+// be empty if it wasn't recognised.
+type Agent struct {
+	Browser string
+	OS      string
+}
+
+func (a Agent) String() string {
+	switch {
+	case a.Browser != "" && a.OS != "":
+		return a.Browser + " on " + a.OS
+	case a.Browser != "":
+		return a.Browser
+	case a.OS != "":
+		return "Unknown browser on " + a.OS
+	default:
+		return "Unknown browser"
+	}
+}
+
+// The order matters: most browsers include the tokens of the ones they were
+// derived from, e.g. Edge's User-Agent also mentions Chrome and Safari.
+var browsers = []struct {
+	token string
+	name  string
+}{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+var systems = []struct {
+	token string
+	name  string
+}{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Windows", "Windows"},
+	{"Linux", "Linux"},
+}
+
+// Parse describes the User-Agent header ua.
+func Parse(ua string) Agent {
+	var a Agent
+
+	for _, b := range browsers {
+		if strings.Contains(ua, b.token) {
+			a.Browser = b.name
+			break
+		}
+	}
+
+	for _, s := range systems {
+		if strings.Contains(ua, s.token) {
+			a.OS = s.name
+			break
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,62 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{
+			name: "Firefox on Linux",
+			ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:126.0) Gecko/20100101 Firefox/126.0",
+			want: "Firefox on Linux",
+		},
+		{
+			name: "Chrome on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36",
+			want: "Chrome on Windows",
+		},
+		{
+			name: "Edge on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36 Edg/125.0.0.0",
+			want: "Edge on Windows",
+		},
+		{
+			name: "Safari on iOS",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			want: "Safari on iOS",
+		},
+		{
+			name: "Safari on macOS",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+			want: "Safari on macOS",
+		},
+		{
+			name: "Chrome on Android",
+			ua:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Mobile Safari/537.36",
+			want: "Chrome on Android",
+		},
+		{
+			name: "Go client",
+			ua:   "Go-http-client/1.1",
+			want: "Unknown browser",
+		},
+		{
+			name: "Empty",
+			ua:   "",
+			want: "Unknown browser",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Parse(tt.ua).String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package validator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BreachedPasswords reports whether a password is known to have appeared
+// in a data breach.
+type BreachedPasswords interface {
+	Breached(password string) (bool, error)
+}
+
+// PasswordPolicy decides whether a new password is good enough.
+type PasswordPolicy struct {
+	MinLength int
+	// MinEntropy is the lowest acceptable estimate from PasswordEntropy, in
+	// bits.
+	MinEntropy float64
+	// Breached is checked for every password unless it's nil.
+	Breached BreachedPasswords
+}
+
+// NewPasswordPolicy returns the policy used for new passwords, checked
+// against the given list of breached passwords.
+func NewPasswordPolicy(breached BreachedPasswords) *PasswordPolicy {
+	return &PasswordPolicy{MinLength: 8, MinEntropy: 35, Breached: breached}
+}
+
+// Check returns a message explaining what's wrong with the password, or an
+// empty string if it's acceptable. personal holds values the password
+// mustn't contain, such as the user's name, username and email address.
+func (p *PasswordPolicy) Check(password string, personal ...string) (string, error) {
+	if !MinChars(password, p.MinLength) {
+		return fmt.Sprintf("This field must be at least %d characters long", p.MinLength), nil
+	}
+
+	if ContainsPersonalInfo(password, personal...) {
+		return "This password contains your name, username or email address", nil
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Breached(password)
+		if err != nil {
+			return "", err
+		}
+		if breached {
+			return "This password has appeared in a data breach, so it's not safe to use", nil
+		}
+	}
+
+	if PasswordEntropy(password) < p.MinEntropy {
+		return "This password is too easy to guess. Try a longer one, or mix in capital letters, digits and symbols", nil
+	}
+
+	return "", nil
+}
+
+// PasswordEntropy estimates the strength of a password in bits, from the
+// kinds of characters it uses and its length. Repeated characters and runs
+// like "abc" or "321" barely add to the strength, so they're only counted
+// once.
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	var length int
+	var prev rune
+
+	for i, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+
+		if i == 0 || (r != prev && r != prev+1 && r != prev-1) {
+			length++
+		}
+		prev = r
+	}
+
+	var pool int
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+
+	if pool == 0 {
+		return 0
+	}
+
+	return float64(length) * math.Log2(float64(pool))
+}
+
+// ContainsPersonalInfo returns true if the password contains any word of at
+// least three characters from the personal values, ignoring case. Only the
+// part of an email address before the @ is used.
+func ContainsPersonalInfo(password string, personal ...string) bool {
+	password = strings.ToLower(password)
+
+	for _, value := range personal {
+		value, _, _ = strings.Cut(strings.ToLower(value), "@")
+
+		words := strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		for _, word := range words {
+			if utf8.RuneCountInString(word) >= 3 && strings.Contains(password, word) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+//go:embed breached.txt
+var commonPasswordHashes []byte
+
+// CommonPasswords is a small bundled list of the most common passwords.
+var CommonPasswords = parseHashList(commonPasswordHashes)
+
+// HashList is a set of uppercase hex SHA-1 password hashes.
+type HashList map[string]bool
+
+func (l HashList) Breached(password string) (bool, error) {
+	return l[sha1Hex(password)], nil
+}
+
+// parseHashList reads one hash per line, optionally followed by ":count".
+// Blank lines and lines starting with # are ignored.
+func parseHashList(data []byte) HashList {
+	list := make(HashList)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		list[strings.ToUpper(hash)] = true
+	}
+
+	return list
+}
+
+// PrefixDir is a directory of k-anonymity range files, in the format
+// published by Pwned Passwords. Each file is named after the first five hex
+// characters of a SHA-1 hash, e.g. "5BAA6.txt", and lists the remaining 35
+// characters of every breached hash with that prefix, one per line and
+// optionally followed by ":count". Only one small file is read per check,
+// so the full list never has to fit in memory.
+type PrefixDir string
+
+func (d PrefixDir) Breached(password string) (bool, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if err != nil {
+		// No file means no breached passwords with that prefix.
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// BreachedIn combines several lists of breached passwords.
+type BreachedIn []BreachedPasswords
+
+func (lists BreachedIn) Breached(password string) (bool, error) {
+	for _, list := range lists {
+		breached, err := list.Breached(password)
+		if err != nil || breached {
+			return breached, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,110 @@
+package validator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := NewPasswordPolicy(CommonPasswords)
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     string
+	}{
+		{
+			name:     "Strong",
+			password: "Tr0mbone-Galaxy",
+			personal: []string{"Alice", "alice", "alice@example.com"},
+			want:     "",
+		},
+		{
+			name:     "Too short",
+			password: "x9!Kq",
+			want:     "This field must be at least 8 characters long",
+		},
+		{
+			name:     "Contains name",
+			password: "MaryJane2024!",
+			personal: []string{"Mary Jane Watson"},
+			want:     "This password contains your name, username or email address",
+		},
+		{
+			name:     "Contains email address",
+			password: "xx-jwatson-xx",
+			personal: []string{"jwatson@example.com"},
+			want:     "This password contains your name, username or email address",
+		},
+		{
+			name:     "Email domain is allowed",
+			password: "example-Tr0mbone",
+			personal: []string{"mj@example.com"},
+			want:     "",
+		},
+		{
+			name:     "Breached",
+			password: "password123",
+			want:     "This password has appeared in a data breach, so it's not safe to use",
+		},
+		{
+			name:     "Repeated character",
+			password: "aaaaaaaaaaaaaaaa",
+			want:     "This password is too easy to guess. Try a longer one, or mix in capital letters, digits and symbols",
+		},
+		{
+			name:     "Sequence",
+			password: "abcdefghijklmnop",
+			want:     "This password is too easy to guess. Try a longer one, or mix in capital letters, digits and symbols",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.Check(tt.password, tt.personal...)
+			assert.NilError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     float64
+	}{
+		{name: "Empty", password: "", want: 0},
+		{name: "Lowercase", password: "kqzmtxpd", want: 8 * math.Log2(26)},
+		{name: "Mixed", password: "kQ7$", want: 4 * math.Log2(26+26+10+33)},
+		{name: "Run counts once", password: "1234", want: math.Log2(10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, PasswordEntropy(tt.password), tt.want)
+		})
+	}
+}
+
+func TestPrefixDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// SHA-1("password") is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0o644)
+	assert.NilError(t, err)
+
+	breached, err := PrefixDir(dir).Breached("password")
+	assert.NilError(t, err)
+	assert.Equal(t, breached, true)
+
+	// Not in the list.
+	breached, err = PrefixDir(dir).Breached("Tr0mbone-Galaxy")
+	assert.NilError(t, err)
+	assert.Equal(t, breached, false)
+}
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -61,10 +62,43 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
+// UsernameRX matches usernames which can be used in a URL as they are:
+// lowercase letters, digits, hyphens and underscores, starting with a letter
+// or digit.
+var UsernameRX = regexp.MustCompile("^[a-z0-9][a-z0-9_-]*$")
+
+// ReservedUsernames can't be chosen as usernames because they could be
+// mistaken for the site itself.
+var ReservedUsernames = []string{
+	"about", "account", "admin", "administrator", "anonymous", "api", "deleted",
+	"help", "login", "logout", "me", "moderation", "moderator", "root",
+	"security", "signup", "snippet", "snippetbox", "static", "support", "system",
+	"u", "user",
+}
+
+// NotReserved() returns true if a value isn't one of the reserved words,
+// ignoring case.
+func NotReserved(value string, reserved ...string) bool {
+	return !slices.ContainsFunc(reserved, func(word string) bool {
+		return strings.EqualFold(value, word)
+	})
+}
+
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
 }
 
+// Timezone() returns true if a value is the name of a time zone in the IANA
+// Time Zone database, like "Europe/Paris". "Local" isn't accepted, since it
+// depends on the server.
+func Timezone(value string) bool {
+	if value == "" || value == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(value)
+	return err == nil
+}
+
 func (v *Validator) AddNonFieldError(message string) {
 	v.NonFieldErrors = append(v.NonFieldErrors, message)
 }
@@ -0,0 +1,28 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/markponce/snippetbox/internal/assert"
+)
+
+func TestTimezone(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"UTC", true},
+		{"Europe/Paris", true},
+		{"America/Argentina/Buenos_Aires", true},
+		{"", false},
+		{"Local", false},
+		{"Mars/Olympus_Mons", false},
+		{"../../etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, Timezone(tt.value), tt.want)
+		})
+	}
+}